@@ -0,0 +1,132 @@
+// Package account provides the shared account model used by CommandLogin's Register, Login, and Delete, along with storage backends.
+package account
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+var (
+	// ErrNotFound is returned when an account does not exist.
+	ErrNotFound = errors.New("account not found")
+	// ErrExists is returned when creating an account whose user already exists.
+	ErrExists = errors.New("account exists")
+	// ErrEmailExists is returned when creating an account with an email that is already used.
+	ErrEmailExists = errors.New("email already in use")
+	// ErrBadCredentials is returned when authenticating with an unknown user or wrong password.
+	ErrBadCredentials = errors.New("bad user or password")
+	// ErrInvalidName is returned for user names that fail ValidName.
+	ErrInvalidName = errors.New("invalid user name")
+	// ErrUserChanged is returned by Update if the update changes the account's User.
+	ErrUserChanged = errors.New("account user cannot be changed")
+)
+
+// Account is a single user account. Passwords are never stored, only their Verifier.
+type Account struct {
+	User      string
+	Email     string
+	Verifier  network.Verifier
+	Created   time.Time
+	TwoFactor TwoFactor `json:",omitzero"`
+	DeleteAt  time.Time `json:",omitzero"` // DeleteAt is when the account is scheduled to be deleted, if set. See Deleter.
+}
+
+// AccountStore is the storage of accounts. User names are matched ignoring case, but accounts keep the User they were created with. Update makes any change to an existing account, so that new Account fields need no new methods.
+type AccountStore interface {
+	// Create creates a new account with the given password Verifier. See HashPassword.
+	Create(user, email string, verifier network.Verifier) (Account, error)
+	// Authenticate returns the account if the password matches, for servers that receive passwords directly.
+	Authenticate(user, password string) (Account, error)
+	// Get returns the account of the given user.
+	Get(user string) (Account, error)
+	// GetByEmail returns the account with the given email, ignoring case.
+	GetByEmail(email string) (Account, error)
+	// ChangePassword replaces the password Verifier of the given user.
+	ChangePassword(user string, verifier network.Verifier) error
+	// Update applies update to the account of the given user and stores the result, atomically with respect to other calls. Nothing is stored if update returns an error, which is then returned. The User may not be changed.
+	Update(user string, update func(a *Account) error) error
+	// Users returns the users of all accounts. They may be normalized by the store, but are always accepted by Get.
+	Users() ([]string, error)
+	// Delete removes the account of the given user.
+	Delete(user string) error
+}
+
+// HashPassword returns a salted Verifier of the given password for storage.
+func HashPassword(password string) (network.Verifier, error) {
+	return network.NewVerifier(password, network.DefaultIterations)
+}
+
+// MaxNameLength is the longest allowed user name.
+const MaxNameLength = 32
+
+// ValidName returns whether the given user name is non-empty, at most MaxNameLength characters, and consists of only letters, digits, underscores, and hyphens.
+func ValidName(user string) bool {
+	if user == "" || len([]rune(user)) > MaxNameLength {
+		return false
+	}
+	for _, r := range user {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// dummyVerifier is checked against when authenticating unknown users so that they take as long as known ones.
+var dummyVerifier = sync.OnceValue(func() network.Verifier {
+	v, _ := HashPassword("")
+	return v
+})
+
+// authenticate checks the password against the account, or against dummyVerifier if the lookup failed.
+func authenticate(a Account, err error, password string) (Account, error) {
+	if err != nil {
+		dummyVerifier().Check(password)
+		if errors.Is(err, ErrNotFound) {
+			return Account{}, ErrBadCredentials
+		}
+		return Account{}, err
+	}
+	if !a.Verifier.Check(password) {
+		return Account{}, ErrBadCredentials
+	}
+	return a, nil
+}
+
+// Verifiers adapts an AccountStore for use as the network.VerifierStore of a network.Authenticator. Accounts registered through it have no email, so servers that collect emails should call Create directly instead.
+func Verifiers(store AccountStore) network.VerifierStore {
+	return verifiers{store}
+}
+
+type verifiers struct {
+	store AccountStore
+}
+
+func (v verifiers) Verifier(user string) (network.Verifier, bool) {
+	a, err := v.store.Get(user)
+	return a.Verifier, err == nil
+}
+
+func (v verifiers) SetVerifier(user string, verifier network.Verifier) error {
+	if _, err := v.store.Get(user); errors.Is(err, ErrNotFound) {
+		_, err = v.store.Create(user, "", verifier)
+		return err
+	}
+	return v.store.ChangePassword(user, verifier)
+}
+
+func (v verifiers) CreateVerifier(user string, verifier network.Verifier) error {
+	_, err := v.store.Create(user, "", verifier)
+	if errors.Is(err, ErrExists) {
+		return network.ErrUserExists
+	}
+	return err
+}
+
+func (v verifiers) DeleteVerifier(user string) error {
+	return v.store.Delete(user)
+}
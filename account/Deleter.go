@@ -0,0 +1,223 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// DefaultGracePeriod is how long accounts remain after a deletion request by default.
+const DefaultGracePeriod = 7 * 24 * time.Hour
+
+var (
+	// ErrConfirmationRequired is returned when a deletion is requested without the account's password.
+	ErrConfirmationRequired = errors.New("password confirmation required")
+	// ErrCodeRequired is returned when a deletion of an account with two-factor authentication is requested without a code.
+	ErrCodeRequired = errors.New("two-factor code required")
+)
+
+// Deleter handles account deletion, as requested by the Delete CommandLogin type. Deletion must be confirmed with the password, and with a two-factor code if the account requires one, after which the account remains for GracePeriod. Logging in during the grace period cancels the deletion. Plaintext logins do so by authenticating through Authenticate, and challenge-response logins by setting Verified as the network.Authenticator's OnVerified. Deletion is cancelled once the password is verified, even if a second factor is still to come, as cancelling only ever keeps the account.
+type Deleter struct {
+	Store       AccountStore
+	GracePeriod time.Duration         // GracePeriod before accounts are deleted. Accounts are deleted immediately if zero.
+	OnDelete    func(a Account) error // OnDelete, if set, is called after an account is deleted, such as to delete its characters. Its errors are returned, but the account stays deleted.
+	Tokens      *TokenStore           // Tokens, if set, has the tokens of deleted accounts revoked, such as Recovery.Tokens.
+	TwoFactor   *TwoFactorAuth        // TwoFactor, if set, verifies the code of accounts with two-factor authentication.
+	locks       userLocks
+}
+
+// NewDeleter returns a Deleter with DefaultGracePeriod.
+func NewDeleter(store AccountStore, onDelete func(a Account) error) *Deleter {
+	return &Deleter{
+		Store:       store,
+		GracePeriod: DefaultGracePeriod,
+		OnDelete:    onDelete,
+	}
+}
+
+// Request confirms the password and any two-factor code, and schedules the account for deletion. It returns when the account will be deleted.
+func (d *Deleter) Request(user, password, code string) (time.Time, error) {
+	if password == "" {
+		return time.Time{}, ErrConfirmationRequired
+	}
+	if _, err := d.Store.Authenticate(user, password); err != nil {
+		return time.Time{}, err
+	}
+	return d.Schedule(user, code)
+}
+
+// Schedule confirms any two-factor code and schedules the account for deletion without confirming the password. It is for servers that have already confirmed the password, such as with a challenge-response Delete.
+func (d *Deleter) Schedule(user, code string) (time.Time, error) {
+	a, err := d.Store.Get(user)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if d.TwoFactor != nil && d.TwoFactor.Required(a) {
+		if code == "" {
+			return time.Time{}, ErrCodeRequired
+		}
+		if err := d.TwoFactor.Verify(user, code); err != nil {
+			return time.Time{}, err
+		}
+	}
+	if d.GracePeriod <= 0 {
+		unlock := d.locks.acquire(user)
+		defer unlock()
+		_, err := d.delete(a)
+		return time.Now(), err
+	}
+	var at time.Time
+	return at, d.Store.Update(user, func(a *Account) error {
+		if at = a.DeleteAt; at.IsZero() {
+			at = time.Now().Add(d.GracePeriod)
+			a.DeleteAt = at
+		}
+		return nil
+	})
+}
+
+// Cancel cancels the scheduled deletion of the account, returning whether there was one. An account that is being purged is not cancelled.
+func (d *Deleter) Cancel(user string) (cancelled bool, err error) {
+	unlock := d.locks.acquire(user)
+	defer unlock()
+	err = d.Store.Update(user, func(a *Account) error {
+		cancelled = !a.DeleteAt.IsZero()
+		a.DeleteAt = time.Time{}
+		return nil
+	})
+	return
+}
+
+// Authenticate returns the account if the password matches, cancelling any scheduled deletion. It is for plaintext logins in place of AccountStore.Authenticate.
+func (d *Deleter) Authenticate(user, password string) (Account, error) {
+	a, err := d.Store.Authenticate(user, password)
+	if err != nil {
+		return a, err
+	}
+	if _, err := d.Cancel(user); err != nil {
+		return Account{}, err
+	}
+	return a, nil
+}
+
+// Verified cancels any scheduled deletion of the session's user if it is a Login. It is for use as the OnVerified of a network.Authenticator using Verifiers.
+func (d *Deleter) Verified(s *network.AuthSession) error {
+	if s.LoginType != network.Login {
+		return nil
+	}
+	_, err := d.Cancel(s.User)
+	return err
+}
+
+// Purge deletes all accounts whose grace period has passed, returning their users.
+func (d *Deleter) Purge() (deleted []string, err error) {
+	users, err := d.Store.Users()
+	if err != nil {
+		return
+	}
+	for _, user := range users {
+		ok, purgeErr := d.purge(user)
+		if purgeErr != nil {
+			err = errors.Join(err, fmt.Errorf("%s: %w", user, purgeErr))
+		}
+		if ok {
+			deleted = append(deleted, user)
+		}
+	}
+	return
+}
+
+// purge deletes the account if its grace period has passed, returning whether it was deleted. The account is checked again under its lock so that a concurrent Cancel is never lost.
+func (d *Deleter) purge(user string) (bool, error) {
+	unlock := d.locks.acquire(user)
+	defer unlock()
+	a, err := d.Store.Get(user)
+	if err != nil || a.DeleteAt.IsZero() || a.DeleteAt.After(time.Now()) {
+		return false, nil
+	}
+	return d.delete(a)
+}
+
+// Run calls Purge every interval until the context is done. Errors are passed to onError if it is set.
+func (d *Deleter) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.Purge(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// delete deletes the account and then calls OnDelete, returning whether the account was deleted. The account's lock must be held.
+func (d *Deleter) delete(a Account) (deleted bool, err error) {
+	if err := d.Store.Delete(a.User); err != nil {
+		return false, err
+	}
+	if d.Tokens != nil {
+		d.Tokens.Revoke(a.User)
+	}
+	if d.OnDelete != nil {
+		return true, d.OnDelete(a)
+	}
+	return true, nil
+}
+
+// Handle processes a plaintext Delete CommandLogin and returns the reply for the client.
+func (d *Deleter) Handle(cmd network.CommandLogin) network.CommandBasic {
+	at, err := d.Request(cmd.User, cmd.Pass, cmd.Code)
+	if err != nil {
+		return network.CommandBasic{Type: network.Reject, String: err.Error()}
+	}
+	if d.GracePeriod <= 0 {
+		return network.CommandBasic{Type: network.Okay, String: "Account deleted."}
+	}
+	return network.CommandBasic{Type: network.Okay, String: fmt.Sprintf("Account will be deleted at %s unless you log in before then.", at.UTC().Format(time.RFC1123))}
+}
+
+// userLocks are per-user mutexes, kept only while they are in use.
+type userLocks struct {
+	lock  sync.Mutex
+	users map[string]*userLock
+}
+
+type userLock struct {
+	sync.Mutex
+	refs int
+}
+
+// acquire locks the given user, ignoring case, and returns the function that unlocks it.
+func (l *userLocks) acquire(user string) (unlock func()) {
+	user = strings.ToLower(user)
+	l.lock.Lock()
+	if l.users == nil {
+		l.users = make(map[string]*userLock)
+	}
+	u, ok := l.users[user]
+	if !ok {
+		u = &userLock{}
+		l.users[user] = u
+	}
+	u.refs++
+	l.lock.Unlock()
+
+	u.Lock()
+	return func() {
+		u.Unlock()
+		l.lock.Lock()
+		if u.refs--; u.refs == 0 {
+			delete(l.users, user)
+		}
+		l.lock.Unlock()
+	}
+}
@@ -0,0 +1,256 @@
+package account
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chimera-rpg/go-common/account/totp"
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// testDeleter returns a Deleter with an hour's grace period for a store holding Alice.
+func testDeleter(t *testing.T) (*Deleter, *FileStore) {
+	t.Helper()
+	store := testStore(t)
+	if _, err := store.Create("Alice", "", testVerifier(t, "password")); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDeleter(store, nil)
+	d.GracePeriod = time.Hour
+	return d, store
+}
+
+// expire moves the scheduled deletion of the user into the past.
+func expire(t *testing.T, store AccountStore, user string) {
+	t.Helper()
+	if err := store.Update(user, func(a *Account) error {
+		a.DeleteAt = time.Now().Add(-time.Second)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleterGracePeriod(t *testing.T) {
+	d, store := testDeleter(t)
+	var deleted []string
+	d.OnDelete = func(a Account) error {
+		deleted = append(deleted, a.User)
+		return nil
+	}
+	if _, err := d.Request("Alice", "", ""); !errors.Is(err, ErrConfirmationRequired) {
+		t.Fatalf("no password got %v, want %v", err, ErrConfirmationRequired)
+	}
+	if _, err := d.Request("Alice", "wrong", ""); !errors.Is(err, ErrBadCredentials) {
+		t.Fatalf("wrong password got %v, want %v", err, ErrBadCredentials)
+	}
+	at, err := d.Request("Alice", "password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if until := time.Until(at); until <= 59*time.Minute || until > time.Hour {
+		t.Fatalf("deletion scheduled in %v, want the grace period", until)
+	}
+	// Requesting again keeps the original time.
+	if again, err := d.Request("Alice", "password", ""); err != nil || !again.Equal(at) {
+		t.Fatalf("second request got %v, %v, want %v", again, err, at)
+	}
+
+	if purged, err := d.Purge(); err != nil || len(purged) != 0 {
+		t.Fatalf("purged %v, %v within the grace period", purged, err)
+	}
+	if _, err := store.Get("Alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	expire(t, store, "Alice")
+	purged, err := d.Purge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(purged, []string{"alice"}) || !slices.Equal(deleted, []string{"Alice"}) {
+		t.Fatalf("purged %v and called OnDelete for %v", purged, deleted)
+	}
+	if _, err := store.Get("Alice"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("purged account got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestDeleterImmediate(t *testing.T) {
+	d, store := testDeleter(t)
+	d.GracePeriod = 0
+	reply := d.Handle(network.CommandLogin{Type: network.Delete, User: "Alice", Pass: "password"})
+	if reply.Type != network.Okay {
+		t.Fatalf("got %+v", reply)
+	}
+	if _, err := store.Get("Alice"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted account got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestDeleterOnDelete(t *testing.T) {
+	d, store := testDeleter(t)
+	d.Tokens = NewTokenStore(0)
+	token, err := d.Tokens.Issue("Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errCharacters := errors.New("characters not deleted")
+	d.OnDelete = func(a Account) error {
+		// The account is already gone.
+		if _, err := store.Get(a.User); !errors.Is(err, ErrNotFound) {
+			t.Errorf("OnDelete called before delete: %v", err)
+		}
+		return errCharacters
+	}
+	if _, err := d.Request("Alice", "password", ""); err != nil {
+		t.Fatal(err)
+	}
+	expire(t, store, "Alice")
+	purged, err := d.Purge()
+	if !errors.Is(err, errCharacters) {
+		t.Fatalf("got %v, want the error of OnDelete", err)
+	}
+	// The account stays deleted despite the error.
+	if !slices.Equal(purged, []string{"alice"}) {
+		t.Fatalf("purged %v", purged)
+	}
+	if err := d.Tokens.Redeem(token, func(string) error { return nil }); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token of deleted account got %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestDeleterCancelOnAuthenticate(t *testing.T) {
+	d, store := testDeleter(t)
+	if _, err := d.Request("Alice", "password", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Authenticate("Alice", "wrong"); !errors.Is(err, ErrBadCredentials) {
+		t.Fatalf("wrong password got %v, want %v", err, ErrBadCredentials)
+	}
+	if a, _ := store.Get("Alice"); a.DeleteAt.IsZero() {
+		t.Fatal("wrong password cancelled the deletion")
+	}
+	if _, err := d.Authenticate("alice", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := store.Get("Alice"); !a.DeleteAt.IsZero() {
+		t.Fatal("login did not cancel the deletion")
+	}
+}
+
+func TestDeleterCancelOnVerified(t *testing.T) {
+	d, store := testDeleter(t)
+	auth, err := network.NewAuthenticator(Verifiers(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.OnVerified = d.Verified
+
+	login := func(loginType uint8) {
+		t.Helper()
+		client := network.NewAuthClient("Alice", "password")
+		start, err := client.Start(loginType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, challenge, err := auth.Challenge(start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := client.Respond(challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := session.Verify(proof); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := d.Request("Alice", "password", ""); err != nil {
+		t.Fatal(err)
+	}
+	// A challenge-response Delete is not a login.
+	login(network.Delete)
+	if a, _ := store.Get("Alice"); a.DeleteAt.IsZero() {
+		t.Fatal("Delete cancelled the deletion")
+	}
+	login(network.Login)
+	if a, _ := store.Get("Alice"); !a.DeleteAt.IsZero() {
+		t.Fatal("login did not cancel the deletion")
+	}
+}
+
+func TestDeleterPurgeRacingCancel(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		d, store := testDeleter(t)
+		if _, err := d.Request("Alice", "password", ""); err != nil {
+			t.Fatal(err)
+		}
+		expire(t, store, "Alice")
+
+		var wg sync.WaitGroup
+		var purged []string
+		var purgeErr, cancelErr error
+		var cancelled bool
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			purged, purgeErr = d.Purge()
+		}()
+		go func() {
+			defer wg.Done()
+			cancelled, cancelErr = d.Cancel("Alice")
+		}()
+		wg.Wait()
+		if purgeErr != nil {
+			t.Fatal(purgeErr)
+		}
+
+		_, err := store.Get("Alice")
+		if len(purged) > 0 {
+			// Purge won, so there was nothing left to cancel.
+			if cancelled || !errors.Is(cancelErr, ErrNotFound) || !errors.Is(err, ErrNotFound) {
+				t.Fatalf("purged, but Cancel got %v, %v and the account %v", cancelled, cancelErr, err)
+			}
+		} else if !cancelled || cancelErr != nil || err != nil {
+			// Cancel won, so the account must remain.
+			t.Fatalf("not purged, but Cancel got %v, %v and the account %v", cancelled, cancelErr, err)
+		}
+	}
+}
+
+func TestDeleterTwoFactor(t *testing.T) {
+	d, store := testDeleter(t)
+	d.TwoFactor = &TwoFactorAuth{Store: store}
+	e, _, err := d.TwoFactor.Enroll("Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrolled, err := d.TwoFactor.Confirm(e, totp.Code(e.Secret, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Request("Alice", "password", ""); !errors.Is(err, ErrCodeRequired) {
+		t.Fatalf("no code got %v, want %v", err, ErrCodeRequired)
+	}
+	if _, err := d.Schedule("Alice", ""); !errors.Is(err, ErrCodeRequired) {
+		t.Fatalf("Schedule without code got %v, want %v", err, ErrCodeRequired)
+	}
+	if _, err := d.Request("Alice", "password", "000000"); !errors.Is(err, ErrBadCode) {
+		t.Fatalf("wrong code got %v, want %v", err, ErrBadCode)
+	}
+	if a, _ := store.Get("Alice"); !a.DeleteAt.IsZero() {
+		t.Fatal("deletion scheduled without a valid code")
+	}
+	if _, err := d.Request("Alice", "password", enrolled.Codes[0]); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := store.Get("Alice"); a.DeleteAt.IsZero() {
+		t.Fatal("deletion not scheduled")
+	}
+}
@@ -0,0 +1,217 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// FileStore is an AccountStore that keeps each account as a JSON file named after its lowercased user within a directory. It suits small servers and tests. Emails are indexed in memory when the store is opened.
+type FileStore struct {
+	Dir     string
+	lock    sync.Mutex
+	byEmail map[string]string // Lowercased email to lowercased user.
+}
+
+// OpenFileStore opens the FileStore in the given directory, creating the directory if needed.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &FileStore{
+		Dir:     dir,
+		byEmail: make(map[string]string),
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range matches {
+		a, err := s.read(strings.TrimSuffix(filepath.Base(match), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if a.Email != "" {
+			s.byEmail[strings.ToLower(a.Email)] = fileName(a.User)
+		}
+	}
+	return s, nil
+}
+
+// fileName returns the name the user's file is stored under, so that names differing only in case are the same account.
+func fileName(user string) string {
+	return strings.ToLower(user)
+}
+
+func (s *FileStore) path(user string) string {
+	return filepath.Join(s.Dir, fileName(user)+".json")
+}
+
+func (s *FileStore) read(user string) (a Account, err error) {
+	if !ValidName(user) {
+		return a, ErrNotFound
+	}
+	b, err := os.ReadFile(s.path(user))
+	if errors.Is(err, os.ErrNotExist) {
+		return a, ErrNotFound
+	} else if err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &a)
+	return
+}
+
+// write atomically replaces the file of the given account.
+func (s *FileStore) write(a Account) error {
+	b, err := json.MarshalIndent(a, "", "\t")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.Dir, ".tmp-"+fileName(a.User)+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(a.User))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Create creates a new account with the given password Verifier.
+func (s *FileStore) Create(user, email string, verifier network.Verifier) (Account, error) {
+	if !ValidName(user) {
+		return Account{}, ErrInvalidName
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err := s.read(user); err == nil {
+		return Account{}, ErrExists
+	} else if !errors.Is(err, ErrNotFound) {
+		return Account{}, err
+	}
+	if _, ok := s.byEmail[strings.ToLower(email)]; ok && email != "" {
+		return Account{}, ErrEmailExists
+	}
+	a := Account{
+		User:     user,
+		Email:    email,
+		Verifier: verifier,
+		Created:  time.Now(),
+	}
+	if err := s.write(a); err != nil {
+		return Account{}, err
+	}
+	if email != "" {
+		s.byEmail[strings.ToLower(email)] = fileName(user)
+	}
+	return a, nil
+}
+
+// Authenticate returns the account if the password matches.
+func (s *FileStore) Authenticate(user, password string) (Account, error) {
+	a, err := s.Get(user)
+	return authenticate(a, err, password)
+}
+
+// Get returns the account of the given user.
+func (s *FileStore) Get(user string) (Account, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.read(user)
+}
+
+// GetByEmail returns the account with the given email, ignoring case.
+func (s *FileStore) GetByEmail(email string) (Account, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	user, ok := s.byEmail[strings.ToLower(email)]
+	if !ok || email == "" {
+		return Account{}, ErrNotFound
+	}
+	return s.read(user)
+}
+
+// ChangePassword replaces the password Verifier of the given user.
+func (s *FileStore) ChangePassword(user string, verifier network.Verifier) error {
+	return s.Update(user, func(a *Account) error {
+		a.Verifier = verifier
+		return nil
+	})
+}
+
+// Update applies update to the account of the given user and stores the result.
+func (s *FileStore) Update(user string, update func(a *Account) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	a, err := s.read(user)
+	if err != nil {
+		return err
+	}
+	old := a
+	if err := update(&a); err != nil {
+		return err
+	}
+	if a.User != old.User {
+		return ErrUserChanged
+	}
+	email := strings.ToLower(a.Email)
+	changed := email != strings.ToLower(old.Email)
+	if _, ok := s.byEmail[email]; ok && changed && email != "" {
+		return ErrEmailExists
+	}
+	if err := s.write(a); err != nil {
+		return err
+	}
+	if changed {
+		delete(s.byEmail, strings.ToLower(old.Email))
+		if email != "" {
+			s.byEmail[email] = fileName(a.User)
+		}
+	}
+	return nil
+}
+
+// Users returns the lowercased users of all accounts.
+func (s *FileStore) Users() ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	matches, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	users := make([]string, 0, len(matches))
+	for _, match := range matches {
+		users = append(users, strings.TrimSuffix(filepath.Base(match), ".json"))
+	}
+	return users, nil
+}
+
+// Delete removes the account of the given user.
+func (s *FileStore) Delete(user string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	a, err := s.read(user)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(user)); err != nil {
+		return err
+	}
+	delete(s.byEmail, strings.ToLower(a.Email))
+	return nil
+}
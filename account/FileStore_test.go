@@ -0,0 +1,164 @@
+package account
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// testVerifier returns a Verifier of the password that is quicker to check than HashPassword's.
+func testVerifier(t *testing.T, password string) network.Verifier {
+	t.Helper()
+	v, err := network.NewVerifier(password, network.MinIterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// testStore returns an empty FileStore in a temporary directory.
+func testStore(t *testing.T) *FileStore {
+	t.Helper()
+	s, err := OpenFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestFileStoreCreate(t *testing.T) {
+	s := testStore(t)
+	a, err := s.Create("Alice", "alice@example.com", testVerifier(t, "password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.User != "Alice" || a.Email != "alice@example.com" || a.Created.IsZero() {
+		t.Fatalf("created %+v", a)
+	}
+	got, err := s.Get("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.User != "Alice" {
+		t.Fatalf("got user %q, want the name it was created with", got.User)
+	}
+	if _, err := s.Create("bad name", "", testVerifier(t, "password")); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("invalid name got %v, want %v", err, ErrInvalidName)
+	}
+}
+
+func TestFileStoreDuplicates(t *testing.T) {
+	s := testStore(t)
+	if _, err := s.Create("Alice", "alice@example.com", testVerifier(t, "password")); err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"Alice", "alice", "ALICE"} {
+		if _, err := s.Create(user, "", testVerifier(t, "password")); !errors.Is(err, ErrExists) {
+			t.Errorf("%s: got %v, want %v", user, err, ErrExists)
+		}
+	}
+	if _, err := s.Create("Bob", "ALICE@example.com", testVerifier(t, "password")); !errors.Is(err, ErrEmailExists) {
+		t.Fatalf("duplicate email got %v, want %v", err, ErrEmailExists)
+	}
+}
+
+func TestFileStoreAuthenticate(t *testing.T) {
+	s := testStore(t)
+	if _, err := s.Create("Alice", "", testVerifier(t, "password")); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := s.Authenticate("alice", "password"); err != nil || a.User != "Alice" {
+		t.Fatalf("right password got %+v, %v", a, err)
+	}
+	if _, err := s.Authenticate("Alice", "wrong"); !errors.Is(err, ErrBadCredentials) {
+		t.Fatalf("wrong password got %v, want %v", err, ErrBadCredentials)
+	}
+	if _, err := s.Authenticate("Bob", "password"); !errors.Is(err, ErrBadCredentials) {
+		t.Fatalf("unknown user got %v, want %v", err, ErrBadCredentials)
+	}
+}
+
+func TestFileStoreDelete(t *testing.T) {
+	s := testStore(t)
+	if _, err := s.Create("Alice", "alice@example.com", testVerifier(t, "password")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("ALICE"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("Alice"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted account got %v, want %v", err, ErrNotFound)
+	}
+	if _, err := s.GetByEmail("alice@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("email of deleted account got %v, want %v", err, ErrNotFound)
+	}
+	if err := s.Delete("Alice"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second delete got %v, want %v", err, ErrNotFound)
+	}
+	// The name and email are free again.
+	if _, err := s.Create("alice", "alice@example.com", testVerifier(t, "password")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileStoreGetByEmail(t *testing.T) {
+	s := testStore(t)
+	if _, err := s.Create("Alice", "Alice@Example.com", testVerifier(t, "password")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create("Bob", "", testVerifier(t, "password")); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := s.GetByEmail("alice@example.COM"); err != nil || a.User != "Alice" {
+		t.Fatalf("got %+v, %v", a, err)
+	}
+	if _, err := s.GetByEmail(""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("empty email got %v, want %v", err, ErrNotFound)
+	}
+	if err := s.Update("Alice", func(a *Account) error {
+		a.Email = "new@example.com"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetByEmail("alice@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("old email got %v, want %v", err, ErrNotFound)
+	}
+	if a, err := s.GetByEmail("new@example.com"); err != nil || a.User != "Alice" {
+		t.Fatalf("new email got %+v, %v", a, err)
+	}
+}
+
+func TestFileStoreReload(t *testing.T) {
+	s := testStore(t)
+	if _, err := s.Create("Alice", "alice@example.com", testVerifier(t, "password")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create("Bob", "", testVerifier(t, "password")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ChangePassword("Bob", testVerifier(t, "changed")); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenFileStore(s.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := s.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(users)
+	if !slices.Equal(users, []string{"alice", "bob"}) {
+		t.Fatalf("got users %v", users)
+	}
+	if a, err := s.GetByEmail("alice@example.com"); err != nil || a.User != "Alice" {
+		t.Fatalf("email index not reloaded: %+v, %v", a, err)
+	}
+	if _, err := s.Authenticate("Bob", "changed"); err != nil {
+		t.Fatalf("changed password not reloaded: %v", err)
+	}
+}
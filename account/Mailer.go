@@ -0,0 +1,66 @@
+package account
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Mail is a single email.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+	Time    time.Time
+}
+
+// Mailer sends emails, such as for password recovery.
+type Mailer interface {
+	Send(m Mail) error
+}
+
+// MemoryMailer is a Mailer that keeps sent mail in memory, for tests.
+type MemoryMailer struct {
+	lock sync.Mutex
+	sent []Mail
+}
+
+// Send records the mail.
+func (m *MemoryMailer) Send(mail Mail) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.sent = append(m.sent, mail)
+	return nil
+}
+
+// Sent returns all mail sent so far.
+func (m *MemoryMailer) Sent() []Mail {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]Mail(nil), m.sent...)
+}
+
+// FileMailer is a Mailer that writes each mail as a file within Dir instead of sending it, for local servers.
+type FileMailer struct {
+	Dir   string
+	lock  sync.Mutex
+	count int
+}
+
+// Send writes the mail to a new file named after its time and recipient.
+func (m *FileMailer) Send(mail Mail) error {
+	if mail.Time.IsZero() {
+		mail.Time = time.Now()
+	}
+	if err := os.MkdirAll(m.Dir, 0700); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	m.count++
+	name := fmt.Sprintf("%s-%d-%s.eml", mail.Time.Format("20060102T150405"), m.count, filepath.Base(mail.To))
+	m.lock.Unlock()
+	body := fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n", mail.To, mail.Subject, mail.Time.Format(time.RFC1123Z), mail.Body)
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(body), 0600)
+}
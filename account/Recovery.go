@@ -0,0 +1,184 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// Our default recovery limits.
+const (
+	DefaultUserLimit      = 3           // DefaultUserLimit is how many recovery emails an account is sent per LimitWindow.
+	DefaultRequesterLimit = 10          // DefaultRequesterLimit is how many recoveries a requester may request per LimitWindow.
+	DefaultLimitWindow    = time.Hour   // DefaultLimitWindow is the period that the limits apply to.
+	DefaultMinDuration    = time.Second // DefaultMinDuration is how long Request takes at least.
+)
+
+// ErrRateLimited is returned when too many recoveries are requested.
+var ErrRateLimited = errors.New("too many recovery requests")
+
+// Recovery handles password recovery via emailed tokens, as requested by the Recover and ResetPassword CommandLogin types. Requests are limited per account and per requester, and take MinDuration regardless of whether the account exists, so that neither their replies nor their timing reveal accounts. Servers that delete accounts other than through a Deleter with Tokens set should Revoke the tokens of deleted users.
+type Recovery struct {
+	Store          AccountStore
+	Tokens         *TokenStore
+	Mailer         Mailer
+	Subject        string                               // Subject of recovery emails.
+	Body           func(a Account, token string) string // Body returns the body of a recovery email.
+	UserLimit      int                                  // UserLimit is how many recovery emails an account is sent per LimitWindow. Unlimited if zero.
+	RequesterLimit int                                  // RequesterLimit is how many recoveries a requester may request per LimitWindow. Unlimited if zero.
+	LimitWindow    time.Duration                        // LimitWindow is the period that UserLimit and RequesterLimit apply to.
+	MinDuration    time.Duration                        // MinDuration is how long Request takes at least. It should exceed the time taken to look up an account and send its email.
+	lock           sync.Mutex
+	users          limiter
+	requesters     limiter
+}
+
+// NewRecovery returns a Recovery with a new TokenStore using DefaultTokenTTL, a default email, and the default limits.
+func NewRecovery(store AccountStore, mailer Mailer) *Recovery {
+	return &Recovery{
+		Store:   store,
+		Tokens:  NewTokenStore(DefaultTokenTTL),
+		Mailer:  mailer,
+		Subject: "Password recovery",
+		Body: func(a Account, token string) string {
+			return fmt.Sprintf("A password reset was requested for %s.\n\nRecovery token: %s\n\nIf you did not request this, you can ignore this email.", a.User, token)
+		},
+		UserLimit:      DefaultUserLimit,
+		RequesterLimit: DefaultRequesterLimit,
+		LimitWindow:    DefaultLimitWindow,
+		MinDuration:    DefaultMinDuration,
+	}
+}
+
+// Request emails a recovery token to the account with the given email, or with the given user if there is none. The requester identifies who is asking, such as by their IP address. ErrNotFound is returned if there is no such account or it has no email, and ErrRateLimited if a limit is reached, but clients should not be told either so that they cannot discover accounts.
+func (r *Recovery) Request(requester, userOrEmail string) error {
+	start := time.Now()
+	defer func() {
+		time.Sleep(r.MinDuration - time.Since(start))
+	}()
+	if !r.allow(&r.requesters, r.RequesterLimit, requester) {
+		return ErrRateLimited
+	}
+	a, err := r.Store.GetByEmail(userOrEmail)
+	if errors.Is(err, ErrNotFound) {
+		a, err = r.Store.Get(userOrEmail)
+	}
+	if err != nil {
+		return err
+	}
+	if a.Email == "" {
+		return ErrNotFound
+	}
+	if !r.allow(&r.users, r.UserLimit, strings.ToLower(a.User)) {
+		return ErrRateLimited
+	}
+	t, err := r.Tokens.Issue(a.User)
+	if err != nil {
+		return err
+	}
+	return r.Mailer.Send(Mail{
+		To:      a.Email,
+		Subject: r.Subject,
+		Body:    r.Body(a, t),
+	})
+}
+
+// allow counts a request against the limiter, returning false if it is over the limit.
+func (r *Recovery) allow(l *limiter, limit int, key string) bool {
+	if limit <= 0 {
+		return true
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return l.allow(key, limit, r.LimitWindow, time.Now())
+}
+
+// Reset replaces the password Verifier of the token's account. The token is only consumed if the password is changed.
+func (r *Recovery) Reset(token string, verifier network.Verifier) error {
+	return r.reset(token, func() (network.Verifier, error) {
+		return verifier, nil
+	})
+}
+
+// reset replaces the password Verifier of the token's account with the one returned by newVerifier, which is only called once the token has been found valid.
+func (r *Recovery) reset(token string, newVerifier func() (network.Verifier, error)) error {
+	return r.Tokens.Redeem(token, func(user string) error {
+		verifier, err := newVerifier()
+		if err != nil {
+			return err
+		}
+		if !verifier.Valid() {
+			return network.ErrInvalidVerifier
+		}
+		return r.Store.ChangePassword(user, verifier)
+	})
+}
+
+// Handle processes a Recover or ResetPassword CommandLogin from the given requester and returns the reply for the client. The new password of a ResetPassword is taken from its Verifier, or from Pass for plaintext clients.
+func (r *Recovery) Handle(requester string, cmd network.CommandLogin) network.CommandBasic {
+	switch cmd.Type {
+	case network.Recover:
+		target := cmd.Email
+		if target == "" {
+			target = cmd.User
+		}
+		// Errors are deliberately not reported to the client.
+		r.Request(requester, target)
+		return network.CommandBasic{Type: network.Okay, String: "If the account exists, a recovery email has been sent."}
+	case network.ResetPassword:
+		newVerifier := func() (network.Verifier, error) {
+			return cmd.Verifier, nil
+		}
+		if cmd.Pass != "" {
+			// Hashing is costly, so clients must present a valid token before a password is hashed.
+			newVerifier = func() (network.Verifier, error) {
+				return HashPassword(cmd.Pass)
+			}
+		}
+		if err := r.reset(cmd.Token, newVerifier); err != nil {
+			return network.CommandBasic{Type: network.Reject, String: err.Error()}
+		}
+		return network.CommandBasic{Type: network.Okay, String: "Password changed."}
+	}
+	return network.CommandBasic{Type: network.Nokay}
+}
+
+// limiter counts requests per key within fixed windows.
+type limiter struct {
+	windows map[string]*limitWindow
+	swept   time.Time
+}
+
+type limitWindow struct {
+	start time.Time
+	count int
+}
+
+// allow counts a request for the key, returning false if the key has reached the limit within the current window.
+func (l *limiter) allow(key string, limit int, window time.Duration, now time.Time) bool {
+	if l.windows == nil {
+		l.windows = make(map[string]*limitWindow)
+	}
+	if now.Sub(l.swept) >= window {
+		l.swept = now
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= window {
+				delete(l.windows, k)
+			}
+		}
+	}
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= window {
+		w = &limitWindow{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= limit {
+		return false
+	}
+	w.count++
+	return true
+}
@@ -0,0 +1,159 @@
+package account
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// testRecovery returns a Recovery for a store holding Alice, whose emails only contain the token.
+func testRecovery(t *testing.T) (*Recovery, *FileStore, *MemoryMailer) {
+	t.Helper()
+	store := testStore(t)
+	if _, err := store.Create("Alice", "alice@example.com", testVerifier(t, "password")); err != nil {
+		t.Fatal(err)
+	}
+	mailer := &MemoryMailer{}
+	r := NewRecovery(store, mailer)
+	r.MinDuration = 0
+	r.Body = func(a Account, token string) string {
+		return token
+	}
+	return r, store, mailer
+}
+
+// lastToken returns the token of the last recovery email.
+func lastToken(t *testing.T, mailer *MemoryMailer) string {
+	t.Helper()
+	sent := mailer.Sent()
+	if len(sent) == 0 {
+		t.Fatal("no recovery email sent")
+	}
+	return sent[len(sent)-1].Body
+}
+
+func TestRecoveryReset(t *testing.T) {
+	r, store, mailer := testRecovery(t)
+	if err := r.Request("requester", "ALICE@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if sent := mailer.Sent(); len(sent) != 1 || sent[0].To != "alice@example.com" {
+		t.Fatalf("sent %+v", sent)
+	}
+	token := lastToken(t, mailer)
+	if err := r.Reset(token, testVerifier(t, "changed")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Authenticate("Alice", "changed"); err != nil {
+		t.Fatalf("new password refused: %v", err)
+	}
+	// Tokens are single use.
+	if err := r.Reset(token, testVerifier(t, "again")); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("second reset got %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestRecoveryInvalidVerifierKeepsToken(t *testing.T) {
+	r, _, mailer := testRecovery(t)
+	if err := r.Request("requester", "Alice"); err != nil {
+		t.Fatal(err)
+	}
+	token := lastToken(t, mailer)
+	if err := r.Reset(token, network.Verifier{}); !errors.Is(err, network.ErrInvalidVerifier) {
+		t.Fatalf("invalid verifier got %v, want %v", err, network.ErrInvalidVerifier)
+	}
+	if err := r.Reset(token, testVerifier(t, "changed")); err != nil {
+		t.Fatalf("token consumed by failed reset: %v", err)
+	}
+}
+
+func TestRecoveryExpiry(t *testing.T) {
+	r, _, mailer := testRecovery(t)
+	r.Tokens = NewTokenStore(time.Millisecond)
+	if err := r.Request("requester", "Alice"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if err := r.Reset(lastToken(t, mailer), testVerifier(t, "changed")); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired token got %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestRecoveryRevocation(t *testing.T) {
+	r, _, mailer := testRecovery(t)
+	if err := r.Request("requester", "Alice"); err != nil {
+		t.Fatal(err)
+	}
+	first := lastToken(t, mailer)
+	// A new token revokes the earlier one.
+	if err := r.Request("requester", "Alice"); err != nil {
+		t.Fatal(err)
+	}
+	second := lastToken(t, mailer)
+	if err := r.Reset(first, testVerifier(t, "changed")); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("superseded token got %v, want %v", err, ErrInvalidToken)
+	}
+	r.Tokens.Revoke("Alice")
+	if err := r.Reset(second, testVerifier(t, "changed")); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("revoked token got %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestRecoveryRateLimit(t *testing.T) {
+	r, store, mailer := testRecovery(t)
+	if _, err := store.Create("Bob", "bob@example.com", testVerifier(t, "password")); err != nil {
+		t.Fatal(err)
+	}
+	r.UserLimit = 2
+	r.RequesterLimit = 2
+	for i := 0; i < 2; i++ {
+		if err := r.Request("requester", "Alice"); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	// The limit applies to the account however it is named.
+	if err := r.Request("other", "alice@example.com"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("user over limit got %v, want %v", err, ErrRateLimited)
+	}
+	if err := r.Request("requester", "Bob"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("requester over limit got %v, want %v", err, ErrRateLimited)
+	}
+	if err := r.Request("other", "Bob"); err != nil {
+		t.Fatalf("other requester refused: %v", err)
+	}
+	if sent := len(mailer.Sent()); sent != 3 {
+		t.Fatalf("sent %d emails, want 3", sent)
+	}
+}
+
+func TestRecoveryUnknownAccount(t *testing.T) {
+	r, _, mailer := testRecovery(t)
+	if err := r.Request("requester", "nobody@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrNotFound)
+	}
+	reply := r.Handle("requester", network.CommandLogin{Type: network.Recover, Email: "nobody@example.com"})
+	if reply.Type != network.Okay {
+		t.Fatalf("reply %+v reveals that the account does not exist", reply)
+	}
+	if sent := mailer.Sent(); len(sent) != 0 {
+		t.Fatalf("sent %+v", sent)
+	}
+}
+
+func TestRecoveryHandleReset(t *testing.T) {
+	r, store, mailer := testRecovery(t)
+	reply := r.Handle("requester", network.CommandLogin{Type: network.ResetPassword, Token: "invalid", Pass: "changed"})
+	if reply.Type != network.Reject {
+		t.Fatalf("invalid token got %+v", reply)
+	}
+	r.Handle("requester", network.CommandLogin{Type: network.Recover, User: "Alice"})
+	reply = r.Handle("requester", network.CommandLogin{Type: network.ResetPassword, Token: lastToken(t, mailer), Pass: "changed"})
+	if reply.Type != network.Okay {
+		t.Fatalf("valid token got %+v", reply)
+	}
+	if _, err := store.Authenticate("Alice", "changed"); err != nil {
+		t.Fatalf("new password refused: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package account
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrInvalidToken is returned when redeeming an unknown, used, or expired token.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// DefaultTokenTTL is how long recovery tokens are valid for by default.
+const DefaultTokenTTL = time.Hour
+
+// TokenStore issues single-use tokens for users that expire after TTL. Only hashes of the tokens are kept.
+type TokenStore struct {
+	TTL    time.Duration
+	lock   sync.Mutex
+	tokens map[[sha256.Size]byte]token
+}
+
+type token struct {
+	user    string
+	expires time.Time
+}
+
+// NewTokenStore returns an empty TokenStore with the given TTL, or DefaultTokenTTL if it is zero.
+func NewTokenStore(ttl time.Duration) *TokenStore {
+	if ttl == 0 {
+		ttl = DefaultTokenTTL
+	}
+	return &TokenStore{
+		TTL:    ttl,
+		tokens: make(map[[sha256.Size]byte]token),
+	}
+}
+
+// Issue returns a new token for the given user. Any earlier tokens of the user are revoked.
+func (s *TokenStore) Issue(user string) (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	t := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.revoke(user)
+	s.tokens[sha256.Sum256([]byte(t))] = token{
+		user:    user,
+		expires: time.Now().Add(s.TTL),
+	}
+	return t, nil
+}
+
+// Redeem calls use with the user the given token was issued for, and consumes the token only if use succeeds. The token cannot be redeemed concurrently while use runs.
+func (s *TokenStore) Redeem(t string, use func(user string) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := sha256.Sum256([]byte(t))
+	tok, ok := s.tokens[key]
+	if !ok {
+		return ErrInvalidToken
+	}
+	if time.Now().After(tok.expires) {
+		delete(s.tokens, key)
+		return ErrInvalidToken
+	}
+	if err := use(tok.user); err != nil {
+		return err
+	}
+	delete(s.tokens, key)
+	return nil
+}
+
+// Revoke removes all tokens of the given user.
+func (s *TokenStore) Revoke(user string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.revoke(user)
+}
+
+func (s *TokenStore) revoke(user string) {
+	for key, tok := range s.tokens {
+		if tok.user == user {
+			delete(s.tokens, key)
+		}
+	}
+}
+
+// Purge removes all expired tokens.
+func (s *TokenStore) Purge() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	for key, tok := range s.tokens {
+		if now.After(tok.expires) {
+			delete(s.tokens, key)
+		}
+	}
+}
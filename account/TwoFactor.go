@@ -0,0 +1,194 @@
+package account
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/chimera-rpg/go-common/account/totp"
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// RecoveryCodeCount is the number of recovery codes issued on enrolment.
+const RecoveryCodeCount = 10
+
+// Our default two-factor lockout.
+const (
+	DefaultMaxFailures = 5                // DefaultMaxFailures is how many wrong codes in a row lock an account out.
+	DefaultLockout     = 15 * time.Minute // DefaultLockout is how long an account is locked out for.
+)
+
+var (
+	// ErrBadCode is returned when a one-time password or recovery code is wrong or has already been used.
+	ErrBadCode = errors.New("bad two-factor code")
+	// ErrNotEnrolled is returned when disabling two-factor authentication for an account without it.
+	ErrNotEnrolled = errors.New("two-factor authentication not enabled")
+	// ErrLockedOut is returned when verifying codes for an account that has had too many wrong codes.
+	ErrLockedOut = errors.New("too many wrong two-factor codes, try again later")
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TwoFactor is the two-factor authentication state of an Account.
+type TwoFactor struct {
+	Secret        []byte    // TOTP secret. Two-factor authentication is disabled if empty.
+	LastStep      int64     // LastStep is the last accepted TOTP time step, preventing codes from being reused.
+	RecoveryCodes [][]byte  // SHA-256 hashes of the unused recovery codes.
+	Failures      int       `json:",omitzero"` // Failures is the count of wrong codes since the last accepted one or lockout.
+	LockedUntil   time.Time `json:",omitzero"` // LockedUntil is when a lockout ends, if any.
+}
+
+// Enabled returns whether two-factor authentication is enabled.
+func (tf TwoFactor) Enabled() bool {
+	return len(tf.Secret) > 0
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+func hashRecoveryCode(code string) []byte {
+	h := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return h[:]
+}
+
+// newRecoveryCodes returns RecoveryCodeCount new recovery codes along with their hashes.
+func newRecoveryCodes() (codes []string, hashes [][]byte, err error) {
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err = rand.Read(b); err != nil {
+			return
+		}
+		c := strings.ToLower(base32NoPadding.EncodeToString(b))
+		code := c[:4] + "-" + c[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return
+}
+
+// TwoFactorAuth verifies and manages the two-factor authentication of accounts in a Store. Servers send a CommandAuth AuthCode after a successful Login of an account with two-factor authentication enabled, and only complete the login once Verify accepts the reply. Codes are generated by the totp package.
+type TwoFactorAuth struct {
+	Store       AccountStore
+	Issuer      string        // Issuer shown by authenticator apps.
+	MaxFailures int           // MaxFailures is how many wrong codes in a row lock an account out. Defaults to DefaultMaxFailures.
+	Lockout     time.Duration // Lockout is how long an account is locked out for. Defaults to DefaultLockout.
+}
+
+// Required returns whether the account must pass Verify to log in.
+func (t *TwoFactorAuth) Required(a Account) bool {
+	return a.TwoFactor.Enabled()
+}
+
+// Verify accepts either a current TOTP code or an unused recovery code for the given user. Each is only accepted once. After MaxFailures wrong codes in a row, ErrLockedOut is returned without checking codes until Lockout has passed.
+func (t *TwoFactorAuth) Verify(user, code string) error {
+	var result error
+	err := t.Store.Update(user, func(a *Account) error {
+		tf := &a.TwoFactor
+		if !tf.Enabled() {
+			return ErrNotEnrolled
+		}
+		now := time.Now()
+		if now.Before(tf.LockedUntil) {
+			return ErrLockedOut
+		}
+		if step, ok := totp.Match(tf.Secret, code, now, tf.LastStep); ok {
+			tf.LastStep = step
+			tf.Failures = 0
+			return nil
+		}
+		hash := hashRecoveryCode(code)
+		for i, h := range tf.RecoveryCodes {
+			if subtle.ConstantTimeCompare(h, hash) == 1 {
+				tf.RecoveryCodes = append(tf.RecoveryCodes[:i:i], tf.RecoveryCodes[i+1:]...)
+				tf.Failures = 0
+				return nil
+			}
+		}
+		// The failure is stored, so the update itself succeeds.
+		tf.Failures++
+		if tf.Failures >= t.maxFailures() {
+			tf.Failures = 0
+			tf.LockedUntil = now.Add(t.lockout())
+		}
+		result = ErrBadCode
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+func (t *TwoFactorAuth) maxFailures() int {
+	if t.MaxFailures <= 0 {
+		return DefaultMaxFailures
+	}
+	return t.MaxFailures
+}
+
+func (t *TwoFactorAuth) lockout() time.Duration {
+	if t.Lockout <= 0 {
+		return DefaultLockout
+	}
+	return t.Lockout
+}
+
+// Enrollment is a pending two-factor enrolment awaiting confirmation of its first code.
+type Enrollment struct {
+	User   string
+	Secret []byte
+}
+
+// Enroll starts two-factor enrolment for the given user. The returned AuthEnroll is sent to the client and the Enrollment kept until the client replies with AuthEnrollConfirm.
+func (t *TwoFactorAuth) Enroll(user string) (*Enrollment, network.CommandAuth, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, network.CommandAuth{}, err
+	}
+	return &Enrollment{User: user, Secret: secret}, network.CommandAuth{
+		Type: network.AuthEnroll,
+		URI:  totp.URI(t.Issuer, user, secret),
+	}, nil
+}
+
+// Confirm enables two-factor authentication if the code matches the Enrollment's secret. The returned AuthEnrolled carries the recovery codes, which are only ever shown this once.
+func (t *TwoFactorAuth) Confirm(e *Enrollment, code string) (network.CommandAuth, error) {
+	step, ok := totp.Match(e.Secret, code, time.Now(), 0)
+	if !ok {
+		return network.CommandAuth{}, ErrBadCode
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return network.CommandAuth{}, err
+	}
+	if err := t.Store.Update(e.User, func(a *Account) error {
+		a.TwoFactor = TwoFactor{
+			Secret:        e.Secret,
+			LastStep:      step,
+			RecoveryCodes: hashes,
+		}
+		return nil
+	}); err != nil {
+		return network.CommandAuth{}, err
+	}
+	return network.CommandAuth{
+		Type:  network.AuthEnrolled,
+		Codes: codes,
+	}, nil
+}
+
+// Disable turns off two-factor authentication for the given user after verifying a code.
+func (t *TwoFactorAuth) Disable(user, code string) error {
+	if err := t.Verify(user, code); err != nil {
+		return err
+	}
+	return t.Store.Update(user, func(a *Account) error {
+		a.TwoFactor = TwoFactor{}
+		return nil
+	})
+}
@@ -0,0 +1,79 @@
+package account
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chimera-rpg/go-common/account/totp"
+)
+
+// testTwoFactor returns a TwoFactorAuth for a store holding Alice with two-factor authentication enabled, along with her secret and recovery codes.
+func testTwoFactor(t *testing.T) (*TwoFactorAuth, []byte, []string) {
+	t.Helper()
+	store := testStore(t)
+	if _, err := store.Create("Alice", "", testVerifier(t, "password")); err != nil {
+		t.Fatal(err)
+	}
+	tf := &TwoFactorAuth{Store: store, Issuer: "test"}
+	e, _, err := tf.Enroll("Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrolled, err := tf.Confirm(e, totp.Code(e.Secret, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tf, e.Secret, enrolled.Codes
+}
+
+func TestTwoFactorVerify(t *testing.T) {
+	tf, secret, codes := testTwoFactor(t)
+	a, err := tf.Store.Get("Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The code used to confirm enrolment cannot be used again.
+	confirmed := time.Unix(a.TwoFactor.LastStep*int64(totp.Period/time.Second), 0)
+	if err := tf.Verify("Alice", totp.Code(secret, confirmed)); !errors.Is(err, ErrBadCode) {
+		t.Fatalf("reused code got %v, want %v", err, ErrBadCode)
+	}
+	if err := tf.Verify("Alice", totp.Code(secret, time.Now().Add(totp.Period))); err != nil {
+		t.Fatalf("next code refused: %v", err)
+	}
+	if err := tf.Verify("Alice", codes[0]); err != nil {
+		t.Fatalf("recovery code refused: %v", err)
+	}
+	if err := tf.Verify("Alice", codes[0]); !errors.Is(err, ErrBadCode) {
+		t.Fatalf("reused recovery code got %v, want %v", err, ErrBadCode)
+	}
+}
+
+func TestTwoFactorLockout(t *testing.T) {
+	tf, _, codes := testTwoFactor(t)
+	tf.MaxFailures = 3
+	tf.Lockout = 50 * time.Millisecond
+
+	// Accepted codes reset the count of failures.
+	for i := 0; i < tf.MaxFailures-1; i++ {
+		if err := tf.Verify("Alice", "000000"); !errors.Is(err, ErrBadCode) {
+			t.Fatalf("wrong code got %v, want %v", err, ErrBadCode)
+		}
+	}
+	if err := tf.Verify("Alice", codes[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < tf.MaxFailures; i++ {
+		if err := tf.Verify("Alice", "000000"); !errors.Is(err, ErrBadCode) {
+			t.Fatalf("wrong code %d got %v, want %v", i, err, ErrBadCode)
+		}
+	}
+	if err := tf.Verify("Alice", codes[1]); !errors.Is(err, ErrLockedOut) {
+		t.Fatalf("locked out account got %v, want %v", err, ErrLockedOut)
+	}
+	time.Sleep(tf.Lockout)
+	if err := tf.Verify("Alice", codes[1]); err != nil {
+		t.Fatalf("code refused after lockout: %v", err)
+	}
+}
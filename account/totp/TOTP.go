@@ -0,0 +1,66 @@
+// Package totp generates and checks time-based one-time passwords (RFC 6238). It has no dependencies on the rest of the module so that clients can answer two-factor code requests without the account package.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Our TOTP parameters, per RFC 6238 defaults.
+const (
+	Period = 30 * time.Second
+	Digits = 6
+	Skew   = 1 // Skew is the number of periods before and after the current one that are accepted.
+)
+
+// Code returns the TOTP code of the given secret at time t.
+func Code(secret []byte, t time.Time) string {
+	return hotp(secret, step(t))
+}
+
+// Match returns the time step that the code matches within Skew of t and after lastStep. Accepted steps should be kept as the next lastStep so that codes cannot be reused.
+func Match(secret []byte, code string, t time.Time, lastStep int64) (int64, bool) {
+	current := step(t)
+	for s := current - Skew; s <= current+Skew; s++ {
+		if s > lastStep && subtle.ConstantTimeCompare([]byte(hotp(secret, s)), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// URI returns the otpauth URI of the secret for use with authenticator apps.
+func URI(issuer, user string, secret []byte) string {
+	v := url.Values{}
+	v.Set("secret", base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret))
+	v.Set("issuer", issuer)
+	v.Set("period", fmt.Sprint(int(Period/time.Second)))
+	v.Set("digits", fmt.Sprint(Digits))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+user) + "?" + v.Encode()
+}
+
+func step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// hotp returns the HOTP (RFC 4226) code for the given counter.
+func hotp(secret []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	h := hmac.New(sha1.New, secret)
+	h.Write(msg[:])
+	sum := h.Sum(nil)
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
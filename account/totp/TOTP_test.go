@@ -0,0 +1,72 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// secret is the SHA-1 secret of the RFC 4226 and RFC 6238 test vectors.
+var secret = []byte("12345678901234567890")
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 Appendix D.
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := hotp(secret, int64(counter)); got != code {
+			t.Errorf("counter %d: got %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	// RFC 6238 Appendix B SHA-1 vectors. They have 8 digits, of which Code returns the last Digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		want := tt.code[len(tt.code)-Digits:]
+		if got := Code(secret, time.Unix(tt.unix, 0)); got != want {
+			t.Errorf("%d: got %s, want %s", tt.unix, got, want)
+		}
+	}
+}
+
+func TestMatchSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	for offset := -Skew - 1; offset <= Skew+1; offset++ {
+		code := Code(secret, now.Add(time.Duration(offset)*Period))
+		s, ok := Match(secret, code, now, 0)
+		if want := offset >= -Skew && offset <= Skew; ok != want {
+			t.Errorf("offset %d: got match %v, want %v", offset, ok, want)
+		} else if ok && s != step(now)+int64(offset) {
+			t.Errorf("offset %d: got step %d, want %d", offset, s, step(now)+int64(offset))
+		}
+	}
+}
+
+func TestMatchReplay(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code := Code(secret, now)
+	s, ok := Match(secret, code, now, 0)
+	if !ok {
+		t.Fatal("current code refused")
+	}
+	if _, ok := Match(secret, code, now, s); ok {
+		t.Fatal("code accepted again")
+	}
+	// Earlier codes within the skew are refused once a later one has been accepted.
+	if _, ok := Match(secret, Code(secret, now.Add(-Period)), now, s); ok {
+		t.Fatal("earlier code accepted after a later one")
+	}
+	if _, ok := Match(secret, Code(secret, now.Add(Period)), now, s); !ok {
+		t.Fatal("later code refused")
+	}
+}
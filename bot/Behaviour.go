@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// Behaviour is a scriptable action of a Bot. Step is called once per Bot Interval while the bot is playing.
+type Behaviour interface {
+	Step(b *Bot) error
+}
+
+// BehaviourFunc adapts a function into a Behaviour.
+type BehaviourFunc func(b *Bot) error
+
+// Step calls f(b).
+func (f BehaviourFunc) Step(b *Bot) error {
+	return f(b)
+}
+
+// Wander moves the bot in random directions.
+type Wander struct {
+	Chance   float64 // Chance to move each step, from 0 to 1.
+	Vertical bool    // Vertical allows Up and Down movement.
+}
+
+// Step sends a random directional CommandCmd.
+func (w Wander) Step(b *Bot) error {
+	if b.Rand.Float64() >= w.Chance {
+		return nil
+	}
+	directions := network.Southwest + 1
+	if w.Vertical {
+		directions = network.Down + 1
+	}
+	return b.Send(b.Predictor.Cmd(b.Rand.Intn(directions), nil))
+}
+
+// Chat sends random chat messages.
+type Chat struct {
+	Chance   float64 // Chance to chat each step, from 0 to 1.
+	Type     int     // Type is the CommandMessage Type, such as ChatMessage.
+	Messages []string
+}
+
+// Step sends a random message from Messages.
+func (c Chat) Step(b *Bot) error {
+	if len(c.Messages) == 0 || b.Rand.Float64() >= c.Chance {
+		return nil
+	}
+	return b.Send(network.CommandMessage{
+		Type: c.Type,
+		Body: c.Messages[b.Rand.Intn(len(c.Messages))],
+	})
+}
+
+// Inspect inspects random objects in the bot's world.
+type Inspect struct {
+	Chance float64 // Chance to inspect each step, from 0 to 1.
+}
+
+// Step sends a CommandInspect for a random known object.
+func (i Inspect) Step(b *Bot) error {
+	if b.Rand.Float64() >= i.Chance {
+		return nil
+	}
+	objects := b.World.Objects()
+	if len(objects) == 0 {
+		return nil
+	}
+	return b.Send(network.CommandInspect{
+		ObjectID: objects[b.Rand.Intn(len(objects))].ID,
+	})
+}
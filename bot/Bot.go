@@ -0,0 +1,281 @@
+package bot
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/chimera-rpg/go-common/account/totp"
+	"github.com/chimera-rpg/go-common/network"
+	"github.com/chimera-rpg/go-common/world"
+)
+
+// Our Bot states.
+const (
+	StateHandshaking = iota
+	StateLoggingIn
+	StateChoosingCharacter
+	StatePlaying
+)
+
+// ErrRejected is returned by Run when the server rejects the bot's login or character.
+var ErrRejected = errors.New("rejected by server")
+
+// ErrDisconnected is returned by Run when the server closes the connection.
+var ErrDisconnected = errors.New("disconnected")
+
+// Config is the configuration of a Bot.
+type Config struct {
+	Address   string        // Address of the server.
+	TLS       *tls.Config   // TLS, if set, is used to connect securely.
+	Program   string        // Program name sent in the handshake.
+	User      string        // User to log in as.
+	Pass      string        // Pass to log in with. It is only sent to the server if Plaintext is set.
+	Plaintext bool          // Plaintext logs in by sending the password for servers without challenge-response support. Otherwise the server must prove that it holds the user's Verifier before the login is accepted.
+	TOTP      []byte        // TOTP is the secret used to answer two-factor code requests, if the account has two-factor authentication enabled.
+	Character string        // Character to play. The first available character is used if empty.
+	Interval  time.Duration // Interval between behaviour steps. Defaults to one second.
+	AutoAck   bool          // AutoAck sends a CommandAck for each received world update.
+	Seed      int64         // Seed for the bot's random source. The current time is used if zero.
+}
+
+// Bot is a headless client that logs in, chooses a character, and then runs its Behaviours. A World replica is kept from the received commands.
+type Bot struct {
+	Config
+	Behaviours []Behaviour
+	Conn       network.Connection
+	World      *world.World
+	Predictor  world.Predictor
+	Logger     *slog.Logger                         // Logger is passed to the Connection. slog.Default() is used if nil.
+	OnSend     func(cmd network.Command, err error) // OnSend, if set, is called for each sent command along with the result of sending it.
+	OnReceive  func(cmd network.Command)            // OnReceive, if set, is called for each received command.
+	Rand       *rand.Rand                           // Rand is the bot's random source for use by Behaviours.
+	state      int
+	auth       *network.AuthClient
+}
+
+// New returns a new Bot with the given configuration and behaviours.
+func New(config Config, behaviours ...Behaviour) *Bot {
+	if config.Interval == 0 {
+		config.Interval = time.Second
+	}
+	if config.Program == "" {
+		config.Program = "chimera-bot"
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Bot{
+		Config:     config,
+		Behaviours: behaviours,
+		World:      world.NewWorld(),
+		Rand:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+// State returns the bot's current state.
+func (b *Bot) State() int {
+	return b.state
+}
+
+// Send sends the given command to the server.
+func (b *Bot) Send(cmd network.Command) error {
+	err := b.Conn.Send(cmd)
+	if b.OnSend != nil {
+		b.OnSend(cmd, err)
+	}
+	return err
+}
+
+// Run connects to the server and runs the bot until the context is done, the connection is closed, or the server rejects the bot.
+func (b *Bot) Run(ctx context.Context) (err error) {
+	b.Conn.Logger = b.Logger
+	if b.TLS != nil {
+		err = b.Conn.SecureConnectTo(b.Address, b.TLS)
+	} else {
+		err = b.Conn.ConnectTo(b.Address)
+	}
+	if err != nil {
+		return
+	}
+	cmdChan, closedChan := b.Conn.CmdChan, b.Conn.ClosedChan
+	defer func() {
+		// Drain the command loop so that it does not block forever.
+		go func() {
+			for {
+				select {
+				case <-cmdChan:
+				case <-time.After(time.Second):
+					return
+				}
+			}
+		}()
+	}()
+
+	b.state = StateHandshaking
+	if err = b.Send(network.CommandHandshake{
+		Version: network.Version,
+		Program: b.Program,
+	}); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(b.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			go b.Conn.Close()
+			<-closedChan
+			return ctx.Err()
+		case <-closedChan:
+			return ErrDisconnected
+		case cmd := <-cmdChan:
+			if err = b.handle(cmd); err != nil {
+				go b.Conn.Close()
+				<-closedChan
+				return
+			}
+		case <-ticker.C:
+			if b.state != StatePlaying {
+				continue
+			}
+			for _, behaviour := range b.Behaviours {
+				if err = behaviour.Step(b); err != nil {
+					go b.Conn.Close()
+					<-closedChan
+					return
+				}
+			}
+		}
+	}
+}
+
+// handle processes a command received from the server.
+func (b *Bot) handle(cmd network.Command) error {
+	if b.OnReceive != nil {
+		b.OnReceive(cmd)
+	}
+	if b.World.Apply(cmd) && b.AutoAck {
+		if t, ok := cmd.(network.TickedCommand); ok && t.GetTick() != 0 {
+			if err := b.Send(b.World.Ack()); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch c := cmd.(type) {
+	case network.CommandHandshake:
+		if b.state != StateHandshaking {
+			return nil
+		}
+		b.state = StateLoggingIn
+		if b.Plaintext {
+			return b.Send(network.CommandLogin{
+				Type: network.Login,
+				User: b.User,
+				Pass: b.Pass,
+			})
+		}
+		b.auth = network.NewAuthClient(b.User, b.Pass)
+		login, err := b.auth.Start(network.Login)
+		if err != nil {
+			return err
+		}
+		return b.Send(login)
+	case network.CommandAuth:
+		if b.state != StateLoggingIn {
+			return nil
+		}
+		switch c.Type {
+		case network.AuthChallenge:
+			if b.auth == nil {
+				return nil
+			}
+			proof, err := b.auth.Respond(c)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrRejected, err)
+			}
+			return b.Send(proof)
+		case network.AuthVerified:
+			if b.auth == nil {
+				return nil
+			}
+			if err := b.auth.Verify(c); err != nil {
+				return fmt.Errorf("%w: server %v", ErrRejected, err)
+			}
+		case network.AuthCode:
+			if len(b.TOTP) == 0 {
+				return fmt.Errorf("%w: two-factor code required", ErrRejected)
+			}
+			return b.Send(network.CommandAuth{
+				Type: network.AuthCode,
+				Code: totp.Code(b.TOTP, time.Now()),
+			})
+		}
+	case network.CommandBasic:
+		switch c.Type {
+		case network.Reject, network.Nokay:
+			if b.state == StateLoggingIn || b.state == StateChoosingCharacter {
+				return fmt.Errorf("%w: %s", ErrRejected, c.String)
+			}
+		case network.Okay:
+			if b.state == StateLoggingIn {
+				if err := b.checkVerified(); err != nil {
+					return err
+				}
+				b.state = StateChoosingCharacter
+			}
+		case network.Cya:
+			return ErrDisconnected
+		}
+	case network.CommandCharacter:
+		if c.Type != network.QueryCharacters {
+			return nil
+		}
+		if b.state == StateLoggingIn {
+			if err := b.checkVerified(); err != nil {
+				return err
+			}
+		}
+		b.state = StateChoosingCharacter
+		name := b.Character
+		if name == "" {
+			if len(c.Characters) == 0 {
+				return fmt.Errorf("%w: no characters available", ErrRejected)
+			}
+			name = c.Characters[0]
+		} else if !slices.Contains(c.Characters, name) {
+			return fmt.Errorf("%w: character %q not available", ErrRejected, name)
+		}
+		return b.Send(network.CommandCharacter{
+			Type:       network.ChooseCharacter,
+			Characters: []string{name},
+		})
+	case network.CommandMap, network.CommandSnapshot:
+		b.state = StatePlaying
+		if s, ok := c.(network.CommandSnapshot); ok {
+			if p, ok := b.World.ObjectPosition(s.ViewTarget); ok {
+				b.Predictor.Reset(p)
+			}
+		}
+	case network.CommandInputAck:
+		b.Predictor.Reconcile(c)
+	}
+	return nil
+}
+
+// checkVerified returns an error if the login used challenge-response but the server never proved that it holds the user's Verifier.
+func (b *Bot) checkVerified() error {
+	if b.auth != nil && !b.auth.Verified() {
+		return fmt.Errorf("%w: server did not verify the login", ErrRejected)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+// Command capture inspects and replays capture files written by network.Recorder.
+//
+// Usage:
+//
+//	capture dump [-json] [-types H,Tt,...] [-direction sent|received] file...
+//	capture stats [-types H,Tt,...] [-direction sent|received] file...
+//	capture replay [-listen :1337] [-speed 1] [-max-gap 5s] [-direction sent] file...
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	network.RegisterCommands()
+
+	var err error
+	switch os.Args[1] {
+	case "dump":
+		err = dump(os.Args[2:])
+	case "stats":
+		err = stats(os.Args[2:])
+	case "replay":
+		err = replay(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s dump|stats|replay [flags] file...\n", os.Args[0])
+	os.Exit(2)
+}
+
+// filter decides which records are processed.
+type filter struct {
+	types     string
+	direction string
+}
+
+func (f *filter) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.types, "types", "", "comma-separated registered command names to include, such as "+strings.Join(network.CommandNames()[:3], ","))
+	fs.StringVar(&f.direction, "direction", "", "only include commands that were \"sent\" or \"received\"")
+}
+
+func (f *filter) validate() error {
+	if f.types != "" {
+		names := network.CommandNames()
+		for _, t := range strings.Split(f.types, ",") {
+			if !slices.Contains(names, t) {
+				return fmt.Errorf("unknown command name %q, expected one of %s", t, strings.Join(names, ","))
+			}
+		}
+	}
+	if f.direction != "" && f.direction != network.Sent.String() && f.direction != network.Received.String() {
+		return fmt.Errorf("unknown direction %q", f.direction)
+	}
+	return nil
+}
+
+func (f *filter) match(r network.CaptureRecord) bool {
+	if f.direction != "" && r.Direction.String() != f.direction {
+		return false
+	}
+	if f.types != "" && !slices.Contains(strings.Split(f.types, ","), network.CommandName(r.Command)) {
+		return false
+	}
+	return true
+}
+
+// each calls fn for every matching record in the given files.
+func each(files []string, f *filter, fn func(network.CaptureRecord) error) error {
+	for _, file := range files {
+		fh, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		err = eachInReader(fh, f, fn)
+		fh.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func eachInReader(r io.Reader, f *filter, fn func(network.CaptureRecord) error) error {
+	cr, err := network.NewCaptureReader(r)
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := cr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !f.match(record) {
+			continue
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+func dump(args []string) error {
+	var f filter
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output records as JSON lines")
+	f.register(fs)
+	fs.Parse(args)
+	if err := f.validate(); err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	return each(fs.Args(), &f, func(r network.CaptureRecord) error {
+		name := network.CommandName(r.Command)
+		if *asJSON {
+			return encoder.Encode(struct {
+				Time      string
+				Direction string
+				Name      string
+				Command   network.Command
+			}{
+				Time:      r.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+				Direction: r.Direction.String(),
+				Name:      name,
+				Command:   r.Command,
+			})
+		}
+		_, err := fmt.Printf("%s %-8s %-3s %T %+v\n", r.Time.Format("15:04:05.000000"), r.Direction, name, r.Command, r.Command)
+		return err
+	})
+}
+
+func stats(args []string) error {
+	var f filter
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	f.register(fs)
+	fs.Parse(args)
+	if err := f.validate(); err != nil {
+		return err
+	}
+
+	type count struct {
+		sent, received int
+	}
+	counts := make(map[string]*count)
+	var total count
+	err := each(fs.Args(), &f, func(r network.CaptureRecord) error {
+		name := network.CommandName(r.Command)
+		c, ok := counts[name]
+		if !ok {
+			c = &count{}
+			counts[name] = c
+		}
+		if r.Direction == network.Sent {
+			c.sent++
+			total.sent++
+		} else {
+			c.received++
+			total.received++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := counts[names[i]], counts[names[j]]
+		if ta, tb := a.sent+a.received, b.sent+b.received; ta != tb {
+			return ta > tb
+		}
+		return names[i] < names[j]
+	})
+	fmt.Printf("%-4s %10s %10s\n", "name", "sent", "received")
+	for _, name := range names {
+		fmt.Printf("%-4s %10d %10d\n", name, counts[name].sent, counts[name].received)
+	}
+	fmt.Printf("%-4s %10d %10d\n", "all", total.sent, total.received)
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// replay listens for a single client and sends it the server side of the given captures.
+func replay(args []string) error {
+	var f filter
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	address := fs.String("listen", ":1337", "address to listen on")
+	speed := fs.Float64("speed", 1, "playback speed multiplier")
+	maxGap := fs.Duration("max-gap", 5*time.Second, "longest pause between records, such as across rotated files or restarts; 0 for no limit")
+	f.register(fs)
+	fs.Parse(args)
+	if f.direction == "" {
+		// Captures are usually recorded on the server.
+		f.direction = network.Sent.String()
+	}
+	if err := f.validate(); err != nil {
+		return err
+	}
+	if *speed <= 0 {
+		return fmt.Errorf("speed must be greater than 0")
+	}
+
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	log.Printf("Waiting for client on %s", listener.Addr())
+	conn, err := listener.Accept()
+	if err != nil {
+		return err
+	}
+	log.Printf("Replaying to %s", conn.RemoteAddr())
+
+	var c network.Connection
+	c.SetConn(conn)
+	go c.LoopCmd()
+	go func() {
+		<-c.ClosedChan
+	}()
+	go func() {
+		// The client's commands are of no interest.
+		for range c.CmdChan {
+		}
+	}()
+
+	var last time.Time
+	err = each(fs.Args(), &f, func(r network.CaptureRecord) error {
+		if !last.IsZero() && r.Time.After(last) {
+			gap := time.Duration(float64(r.Time.Sub(last)) / *speed)
+			if *maxGap > 0 && gap > *maxGap {
+				gap = *maxGap
+			}
+			time.Sleep(gap)
+		}
+		last = r.Time
+		if !c.IsConnected {
+			return fmt.Errorf("client disconnected")
+		}
+		return c.Send(r.Command)
+	})
+	c.Close()
+	return err
+}
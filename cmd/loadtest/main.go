@@ -0,0 +1,147 @@
+// Command loadtest launches many bot clients against a server and reports latency, throughput, and error statistics per command type.
+//
+// Usage:
+//
+//	loadtest [-address localhost:1337] [-bots 100] [-ramp 30s] [-duration 1m] [flags]
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chimera-rpg/go-common/bot"
+	"github.com/chimera-rpg/go-common/network"
+)
+
+func main() {
+	address := flag.String("address", "localhost:1337", "server address")
+	secure := flag.Bool("tls", false, "connect with TLS")
+	insecure := flag.Bool("insecure", false, "skip TLS certificate verification")
+	knownHosts := flag.String("known-hosts", "", "file of pinned server certificate fingerprints, trusting the server's certificate on first use")
+	clientCert := flag.String("client-cert", "", "client certificate file for mutual TLS")
+	clientKey := flag.String("client-key", "", "client private key file for mutual TLS")
+	bots := flag.Int("bots", 100, "number of bots to launch")
+	ramp := flag.Duration("ramp", 30*time.Second, "duration over which bots are launched")
+	duration := flag.Duration("duration", time.Minute, "duration of the test after ramping up")
+	user := flag.String("user", "bot%d", "user name format, given the bot number")
+	pass := flag.String("pass", "bot", "password for all bots")
+	plaintext := flag.Bool("plaintext", false, "send passwords to servers without challenge-response support, such as the mock server for unregistered users")
+	character := flag.String("character", "", "character to choose, the first available if empty")
+	interval := flag.Duration("interval", time.Second, "interval between bot behaviour steps")
+	wander := flag.Float64("wander", 0.5, "chance to move each step")
+	chat := flag.Float64("chat", 0.05, "chance to chat each step")
+	inspect := flag.Float64("inspect", 0.05, "chance to inspect each step")
+	report := flag.Duration("report", 5*time.Second, "interval between progress reports")
+	verbose := flag.Bool("v", false, "log connection events")
+	flag.Parse()
+
+	network.RegisterCommands()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if *verbose {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	var tlsConfig *tls.Config
+	if *secure {
+		tlsConfig = &tls.Config{InsecureSkipVerify: *insecure}
+		if *knownHosts != "" {
+			k, err := network.LoadKnownHosts(*knownHosts)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			k.Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+			tlsConfig = k.TLSConfig(*address)
+		}
+		if *clientCert != "" {
+			cert, err := tls.LoadX509KeyPair(*clientCert, *clientKey)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	results := newResults()
+	ctx, cancel := context.WithTimeout(context.Background(), *ramp+*duration)
+	defer cancel()
+
+	var active atomic.Int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	go progress(ctx, *report, &active, results, start)
+
+	for i := 0; i < *bots; i++ {
+		if *bots > 1 {
+			delay := time.Until(start.Add(*ramp * time.Duration(i) / time.Duration(*bots-1)))
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		name := fmt.Sprintf(*user, i)
+		t := newTracker(results, name)
+		b := bot.New(bot.Config{
+			Address:   *address,
+			TLS:       tlsConfig,
+			User:      name,
+			Pass:      *pass,
+			Plaintext: *plaintext,
+			Character: *character,
+			Interval:  *interval,
+			AutoAck:   true,
+			Seed:      int64(i + 1),
+		},
+			bot.Wander{Chance: *wander},
+			chatProbe{Chat: bot.Chat{Chance: *chat, Type: network.ChatMessage, Messages: []string{"Hello!", "Anyone around?", "Load testing."}}, tracker: t},
+			bot.Inspect{Chance: *inspect},
+		)
+		b.Logger = logger
+		b.OnSend = t.sent
+		b.OnReceive = t.received
+
+		wg.Add(1)
+		active.Add(1)
+		go func() {
+			defer wg.Done()
+			defer active.Add(-1)
+			err := b.Run(ctx)
+			results.finished(err, t.last)
+		}()
+	}
+	wg.Wait()
+	results.print(os.Stdout, time.Since(start))
+}
+
+func progress(ctx context.Context, interval time.Duration, active *atomic.Int64, results *results, start time.Time) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s := network.GlobalStats.Snapshot()
+			var sent, received uint64
+			for _, c := range s.Sent {
+				sent += c.Messages
+			}
+			for _, c := range s.Received {
+				received += c.Messages
+			}
+			fmt.Fprintf(os.Stderr, "%6s active=%d sent=%d received=%d disconnects=%d errors=%d\n", time.Since(start).Truncate(time.Second), active.Load(), sent, received, results.disconnects.Load(), results.errorCount.Load())
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chimera-rpg/go-common/bot"
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// responses maps the names of request commands to the names of the commands that answer them. Chat latency is measured separately with tagged probes. See chatProbe.
+var responses = map[string][]string{
+	"H": {"H"},
+	"L": {"B", "C"},
+	"C": {"M", "Sn", "B"},
+	"I": {"O", "Oo"},
+}
+
+// probePrefix starts the tag that chatProbe appends to each message.
+const probePrefix = "#probe:"
+
+// commandStats are the results for a single command type. Disconnects and run errors are counted against the last command the bot sent.
+type commandStats struct {
+	sent, received, errors, disconnects int
+	latencies                           []time.Duration
+}
+
+// results are the combined results of all bots.
+type results struct {
+	lock        sync.Mutex
+	commands    map[string]*commandStats
+	runErrors   map[string]int
+	disconnects atomic.Int64
+	errorCount  atomic.Int64
+}
+
+func newResults() *results {
+	return &results{
+		commands:  make(map[string]*commandStats),
+		runErrors: make(map[string]int),
+	}
+}
+
+func (r *results) command(name string) *commandStats {
+	c, ok := r.commands[name]
+	if !ok {
+		c = &commandStats{}
+		r.commands[name] = c
+	}
+	return c
+}
+
+// finished records the result of a bot's Run, given the name of the last command the bot sent. Bots that never sent a command are counted against "-".
+func (r *results) finished(err error, last string) {
+	if last == "" {
+		last = "-"
+	}
+	switch {
+	case err == nil, errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+	case errors.Is(err, bot.ErrDisconnected):
+		r.disconnects.Add(1)
+		r.lock.Lock()
+		r.command(last).disconnects++
+		r.lock.Unlock()
+	default:
+		r.errorCount.Add(1)
+		r.lock.Lock()
+		r.command(last).errors++
+		r.runErrors[fmt.Sprintf("%s (after %s)", err, last)]++
+		r.lock.Unlock()
+	}
+}
+
+// tracker pairs a single bot's requests with their responses to measure latency.
+type tracker struct {
+	results *results
+	user    string
+	last    string // Name of the last command sent.
+	pending map[string][]time.Time
+	inputs  []pendingInput
+	probe   int
+	probes  map[string]time.Time // Sent chat probes by tag.
+}
+
+type pendingInput struct {
+	input uint32
+	sent  time.Time
+}
+
+func newTracker(r *results, user string) *tracker {
+	return &tracker{
+		results: r,
+		user:    user,
+		pending: make(map[string][]time.Time),
+		probes:  make(map[string]time.Time),
+	}
+}
+
+// nextProbe returns a new chat probe tag that is unique among all bots.
+func (t *tracker) nextProbe() string {
+	t.probe++
+	return probePrefix + t.user + ":" + strconv.Itoa(t.probe)
+}
+
+// probeTag returns the chat probe tag at the end of the message body, if any.
+func probeTag(body string) string {
+	if i := strings.LastIndex(body, probePrefix); i >= 0 {
+		return body[i:]
+	}
+	return ""
+}
+
+func (t *tracker) sent(cmd network.Command, err error) {
+	name := network.CommandName(cmd)
+	t.last = name
+	t.results.lock.Lock()
+	c := t.results.command(name)
+	c.sent++
+	if err != nil {
+		c.errors++
+	}
+	t.results.lock.Unlock()
+	if err != nil {
+		return
+	}
+	if in, ok := cmd.(network.CommandCmd); ok && in.Input != 0 {
+		t.inputs = append(t.inputs, pendingInput{input: in.Input, sent: time.Now()})
+	} else if msg, ok := cmd.(network.CommandMessage); ok {
+		if tag := probeTag(msg.Body); tag != "" {
+			t.probes[tag] = time.Now()
+		}
+	} else if _, ok := responses[name]; ok {
+		t.pending[name] = append(t.pending[name], time.Now())
+	}
+}
+
+func (t *tracker) received(cmd network.Command) {
+	name := network.CommandName(cmd)
+	now := time.Now()
+	t.results.lock.Lock()
+	defer t.results.lock.Unlock()
+	t.results.command(name).received++
+
+	if ack, ok := cmd.(network.CommandInputAck); ok {
+		c := t.results.command(network.CommandName(network.CommandCmd{}))
+		i := 0
+		for ; i < len(t.inputs) && t.inputs[i].input <= ack.Input; i++ {
+			c.latencies = append(c.latencies, now.Sub(t.inputs[i].sent))
+		}
+		t.inputs = t.inputs[i:]
+		return
+	}
+	// Only this bot's own probes are matched, as other bots' chat is broadcast too.
+	if msg, ok := cmd.(network.CommandMessage); ok {
+		tag := probeTag(msg.Body)
+		if sent, ok := t.probes[tag]; ok {
+			c := t.results.command(name)
+			c.latencies = append(c.latencies, now.Sub(sent))
+			delete(t.probes, tag)
+		}
+		return
+	}
+	for request, names := range responses {
+		if len(t.pending[request]) == 0 || !slices.Contains(names, name) {
+			continue
+		}
+		c := t.results.command(request)
+		c.latencies = append(c.latencies, now.Sub(t.pending[request][0]))
+		t.pending[request] = t.pending[request][1:]
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+// print writes the final report to w.
+func (r *results) print(w io.Writer, elapsed time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	names := make([]string, 0, len(r.commands))
+	for name := range r.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "%-4s %9s %9s %7s %7s %9s %9s %9s %9s %9s %9s\n", "name", "sent", "received", "errors", "discon", "sent/s", "recv/s", "p50", "p90", "p99", "max")
+	for _, name := range names {
+		c := r.commands[name]
+		slices.Sort(c.latencies)
+		fmt.Fprintf(w, "%-4s %9d %9d %7d %7d %9.1f %9.1f %9s %9s %9s %9s\n",
+			name, c.sent, c.received, c.errors, c.disconnects,
+			float64(c.sent)/elapsed.Seconds(), float64(c.received)/elapsed.Seconds(),
+			formatLatency(c.latencies, 0.5), formatLatency(c.latencies, 0.9), formatLatency(c.latencies, 0.99), formatLatency(c.latencies, 1))
+	}
+
+	s := network.GlobalStats.Snapshot()
+	var sentBytes, receivedBytes uint64
+	for _, c := range s.Sent {
+		sentBytes += c.Bytes
+	}
+	for _, c := range s.Received {
+		receivedBytes += c.Bytes
+	}
+	fmt.Fprintf(w, "\nelapsed %s, sent %.1f KiB/s, received %.1f KiB/s\n", elapsed.Truncate(time.Millisecond), float64(sentBytes)/1024/elapsed.Seconds(), float64(receivedBytes)/1024/elapsed.Seconds())
+	fmt.Fprintf(w, "disconnects %d, errors %d\n", r.disconnects.Load(), r.errorCount.Load())
+	for _, kind := range sortedErrorKinds(s.Errors) {
+		fmt.Fprintf(w, "  %s errors: %d\n", kind, s.Errors[kind])
+	}
+	for err, count := range r.runErrors {
+		fmt.Fprintf(w, "  %dx %s\n", count, err)
+	}
+}
+
+func formatLatency(sorted []time.Duration, p float64) string {
+	if len(sorted) == 0 {
+		return "-"
+	}
+	return percentile(sorted, p).Round(10 * time.Microsecond).String()
+}
+
+func sortedErrorKinds(m map[string]uint64) []string {
+	kinds := make([]string, 0, len(m))
+	for k := range m {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// chatProbe is a bot.Chat that tags each message with a probe ID unique to the bot, so that the latency of its own echoed messages can be told apart from other bots' chat.
+type chatProbe struct {
+	bot.Chat
+	tracker *tracker
+}
+
+// Step sends a random tagged message from Messages.
+func (c chatProbe) Step(b *bot.Bot) error {
+	if len(c.Messages) == 0 || b.Rand.Float64() >= c.Chance {
+		return nil
+	}
+	return b.Send(network.CommandMessage{
+		Type: c.Type,
+		Body: c.Messages[b.Rand.Intn(len(c.Messages))] + " " + c.tracker.nextProbe(),
+	})
+}
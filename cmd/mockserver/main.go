@@ -0,0 +1,107 @@
+// Command mockserver runs a lightweight server that speaks the protocol without the full game, for use in client development.
+//
+// Usage:
+//
+//	mockserver [-listen :1337] [-assets dir] [-width 32] [-depth 32] [-characters Mock,...] [-cert cert.pem -key key.pem [-client-ca ca.pem,...]] [-bans bans.txt] [-ban 203.0.113.0/24,...] [-max-per-address 4] [-rate 1 -burst 5]
+//
+// If -cert and -key are given, the server uses TLS, generating a self-signed certificate into them if neither exists. If -client-ca is also given, clients must present a certificate signed by, or equal to, one of its certificates.
+//
+// Addresses given with -ban are banned permanently and saved to the -bans file, if any, along with earlier bans. Each address may hold at most -max-per-address connections and open new ones at -rate per second after an initial -burst.
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chimera-rpg/go-common/mockserver"
+	"github.com/chimera-rpg/go-common/network"
+)
+
+func main() {
+	address := flag.String("listen", ":1337", "address to listen on")
+	assets := flag.String("assets", "", "directory of PNG assets; floor.png, wall.png, and player.png are used for the map and others as items")
+	width := flag.Int("width", 32, "map width")
+	depth := flag.Int("depth", 32, "map depth")
+	characters := flag.String("characters", "Mock", "comma-separated character names offered to every user")
+	seed := flag.Int64("seed", 1, "map generation seed")
+	tick := flag.Duration("tick", 100*time.Millisecond, "world update rate")
+	certFile := flag.String("cert", "", "TLS certificate file")
+	keyFile := flag.String("key", "", "TLS private key file")
+	hosts := flag.String("hosts", "localhost,127.0.0.1", "comma-separated host names and IPs for generated certificates")
+	clientCA := flag.String("client-ca", "", "comma-separated PEM files of trusted client certificates or CAs, requiring mutual TLS")
+	bansFile := flag.String("bans", "", "file banned addresses are loaded from and saved to")
+	ban := flag.String("ban", "", "comma-separated IP addresses or CIDR ranges to ban")
+	maxPerAddress := flag.Int("max-per-address", 0, "maximum concurrent connections per address, or 0 for unlimited")
+	rate := flag.Float64("rate", 0, "new connections per second allowed per address, or 0 for unlimited")
+	burst := flag.Int("burst", 5, "new connections an address may open at once before -rate applies")
+	flag.Parse()
+
+	network.RegisterCommands()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	bans, err := network.LoadBanList(*bansFile)
+	if err != nil {
+		logger.Error("failed to load bans", slog.Any("error", err))
+		os.Exit(1)
+	}
+	if *ban != "" {
+		for _, b := range strings.Split(*ban, ",") {
+			if err := bans.Ban(b, 0, "banned on the command line"); err != nil {
+				logger.Error("failed to ban", slog.String("address", b), slog.Any("error", err))
+				os.Exit(1)
+			}
+		}
+	}
+
+	s, err := mockserver.New(mockserver.Config{
+		Width:      *width,
+		Depth:      *depth,
+		AssetsDir:  *assets,
+		Characters: strings.Split(*characters, ","),
+		Seed:       *seed,
+		TickRate:   *tick,
+		Logger:     logger,
+		Admission: &network.Admitter{
+			Bans:          bans,
+			MaxPerAddress: *maxPerAddress,
+			Rate:          *rate,
+			Burst:         *burst,
+		},
+	})
+	if err != nil {
+		logger.Error("failed to create server", slog.Any("error", err))
+		os.Exit(1)
+	}
+	l, err := net.Listen("tcp", *address)
+	if err != nil {
+		logger.Error("failed to listen", slog.Any("error", err))
+		os.Exit(1)
+	}
+	if *certFile != "" || *keyFile != "" {
+		cert, err := network.LoadOrGenerateCertificate(*certFile, *keyFile, strings.Split(*hosts, ","))
+		if err != nil {
+			logger.Error("failed to load certificate", slog.Any("error", err))
+			os.Exit(1)
+		}
+		logger.Info("using TLS", slog.String("fingerprint", network.Fingerprint(cert.Certificate[0])))
+		conf := &tls.Config{Certificates: []tls.Certificate{cert}}
+		if *clientCA != "" {
+			pool, err := network.LoadCertPool(strings.Split(*clientCA, ",")...)
+			if err != nil {
+				logger.Error("failed to load client CAs", slog.Any("error", err))
+				os.Exit(1)
+			}
+			conf = network.MutualTLSConfig(cert, pool)
+		}
+		l = tls.NewListener(l, conf)
+	}
+	if err := s.Serve(l); err != nil {
+		logger.Error("server stopped", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,80 @@
+// Command protocheck checks the protocol against the golden encodings in network/testdata/golden and fails if it has changed incompatibly.
+//
+// Usage:
+//
+//	protocheck [-dir network/testdata/golden] [-strict]
+//	protocheck -update [-force] [-dir network/testdata/golden]
+//
+// The default -dir is the network package's testdata/golden within the source tree protocheck was built from, so it may be run from any directory.
+//
+// Compatible changes, such as added commands or fields, are reported but only fail with -strict. Intentional changes are recorded by regenerating the golden files with -update. Incompatible changes are only written if network.Version has been bumped or -force is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/chimera-rpg/go-common/network"
+	"github.com/chimera-rpg/go-common/network/golden"
+)
+
+func main() {
+	dir := flag.String("dir", defaultDir(), "golden directory")
+	update := flag.Bool("update", false, "regenerate the golden files")
+	force := flag.Bool("force", false, "regenerate even if incompatible without a version bump")
+	strict := flag.Bool("strict", false, "fail on compatible changes as well")
+	flag.Parse()
+
+	var problems []golden.Problem
+	if _, err := os.Stat(*dir); err == nil || !*update {
+		var err error
+		if problems, err = golden.Check(*dir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	incompatible := 0
+	for _, p := range problems {
+		fmt.Println(p)
+		if p.Incompatible {
+			incompatible++
+		}
+	}
+
+	if *update {
+		if incompatible > 0 && !*force {
+			if s, err := golden.ReadSchema(*dir); err == nil && s.Version == network.Version {
+				fmt.Fprintf(os.Stderr, "%d incompatible changes without a version bump, refusing to update without -force\n", incompatible)
+				os.Exit(1)
+			}
+		}
+		if err := golden.Update(*dir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("updated %s\n", *dir)
+		return
+	}
+
+	if incompatible > 0 || (*strict && len(problems) > 0) {
+		fmt.Fprintf(os.Stderr, "%d problems, %d incompatible\n", len(problems), incompatible)
+		os.Exit(1)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("%d compatible changes, regenerate with -update\n", len(problems))
+	}
+}
+
+// defaultDir returns the golden directory of the source tree this command was built from, falling back to a path relative to the working directory.
+func defaultDir() string {
+	if _, file, _, ok := runtime.Caller(0); ok {
+		dir := filepath.Join(filepath.Dir(file), "..", "..", "network", "testdata", "golden")
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	return filepath.Join("network", "testdata", "golden")
+}
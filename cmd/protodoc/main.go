@@ -0,0 +1,47 @@
+// Command protodoc exports a machine-readable description of the protocol for generating clients in other languages.
+//
+// Usage:
+//
+//	protodoc [-format json|markdown] [-o file]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+func main() {
+	format := flag.String("format", "json", "output format, \"json\" or \"markdown\"")
+	output := flag.String("o", "", "output file, standard output if empty")
+	flag.Parse()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	schema := network.Schema()
+	var err error
+	switch *format {
+	case "json":
+		err = schema.WriteJSON(w)
+	case "markdown", "md":
+		err = schema.WriteMarkdown(w)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,129 @@
+package mockserver
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// Our asset names with special meaning. Any other assets are scattered around the map as items.
+const (
+	FloorAsset  = "floor"
+	WallAsset   = "wall"
+	PlayerAsset = "player"
+)
+
+// Asset is a single image that is sent as both a graphic and a single-frame animation sharing the same ID.
+type Asset struct {
+	ID   uint32
+	Name string
+	Data []byte // PNG data.
+}
+
+// Assets are the graphics and animations served by the mock server.
+type Assets struct {
+	List   []Asset
+	byName map[string]uint32
+}
+
+// ID returns the ID of the named asset.
+func (a *Assets) ID(name string) uint32 {
+	return a.byName[name]
+}
+
+// Items returns the IDs of all assets without special meaning.
+func (a *Assets) Items() (ids []uint32) {
+	for _, asset := range a.List {
+		if asset.Name != FloorAsset && asset.Name != WallAsset && asset.Name != PlayerAsset {
+			ids = append(ids, asset.ID)
+		}
+	}
+	return
+}
+
+// Get returns the asset with the given ID.
+func (a *Assets) Get(id uint32) (Asset, bool) {
+	if id == 0 || int(id) > len(a.List) {
+		return Asset{}, false
+	}
+	return a.List[id-1], true
+}
+
+// Animation returns the single-frame CommandAnimation for the given asset.
+func (a *Assets) Animation(id uint32) network.CommandAnimation {
+	return network.CommandAnimation{
+		Type:        network.Set,
+		AnimationID: id,
+		Faces: map[uint32][]network.AnimationFrame{
+			0: {{ImageID: id, Time: 0}},
+		},
+	}
+}
+
+// LoadAssets loads all PNG files from the given directory, using the file name without its extension as the asset name. Generated placeholders are used for the floor, wall, and player assets if they are missing. If dir is empty, only the placeholders are used.
+func LoadAssets(dir string) (*Assets, error) {
+	a := &Assets{
+		byName: make(map[string]uint32),
+	}
+	if dir != "" {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.png"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			data, err := os.ReadFile(match)
+			if err != nil {
+				return nil, err
+			}
+			a.add(strings.TrimSuffix(filepath.Base(match), filepath.Ext(match)), data)
+		}
+	}
+	placeholders := []struct {
+		name  string
+		color color.RGBA
+	}{
+		{FloorAsset, color.RGBA{96, 96, 96, 255}},
+		{WallAsset, color.RGBA{128, 80, 48, 255}},
+		{PlayerAsset, color.RGBA{48, 96, 192, 255}},
+	}
+	for _, p := range placeholders {
+		if _, ok := a.byName[p.name]; ok {
+			continue
+		}
+		data, err := placeholder(p.color)
+		if err != nil {
+			return nil, err
+		}
+		a.add(p.name, data)
+	}
+	return a, nil
+}
+
+func (a *Assets) add(name string, data []byte) {
+	id := uint32(len(a.List) + 1)
+	a.List = append(a.List, Asset{ID: id, Name: name, Data: data})
+	a.byName[name] = id
+}
+
+// placeholder returns a solid square PNG of the given color.
+func placeholder(c color.RGBA) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,266 @@
+package mockserver
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/chimera-rpg/go-common/data"
+	"github.com/chimera-rpg/go-common/network"
+	"github.com/chimera-rpg/go-common/world"
+)
+
+// Config is the configuration of a mock Server.
+type Config struct {
+	Width, Depth int               // Width and Depth of the generated map. Defaults to 32 by 32.
+	AssetsDir    string            // AssetsDir is the directory PNG assets are loaded from. See LoadAssets.
+	Characters   []string          // Characters offered to every user. Defaults to a single "Mock" character.
+	Seed         int64             // Seed for map generation.
+	TickRate     time.Duration     // TickRate is how often world updates are sent. Defaults to 100ms.
+	Logger       *slog.Logger      // Logger is used for the server and its connections. slog.Default() is used if nil.
+	Admission    network.Admission // Admission, if set, may refuse connections before they are served.
+}
+
+// Server is a lightweight server that speaks the protocol without the full game. It accepts any login except for users registered with a Verifier, who must pass challenge-response. Other users are not challenged, so clients that require the server to prove itself, such as a bot.Bot without Plaintext, must log in with a plaintext password. It offers the configured characters, places players on a generated map, and echoes chat to all players.
+type Server struct {
+	Config
+	Assets   *Assets
+	Auth     *network.Authenticator // Auth holds the users registered with a Verifier.
+	server   network.Server
+	lock     sync.Mutex
+	objects  map[uint32]world.Object
+	tiles    map[world.Position][]uint32
+	nextID   uint32
+	sessions map[*session]struct{}
+	tick     uint32
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// The height of the map. Floors are on the first level and everything else on the second.
+const mapHeight = 2
+
+// New returns a new Server with a generated map.
+func New(config Config) (*Server, error) {
+	if config.Width <= 0 {
+		config.Width = 32
+	}
+	if config.Depth <= 0 {
+		config.Depth = 32
+	}
+	if len(config.Characters) == 0 {
+		config.Characters = []string{"Mock"}
+	}
+	if config.TickRate <= 0 {
+		config.TickRate = 100 * time.Millisecond
+	}
+	assets, err := LoadAssets(config.AssetsDir)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := network.NewAuthenticator(network.NewMemoryVerifierStore())
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Config:   config,
+		Assets:   assets,
+		Auth:     auth,
+		objects:  make(map[uint32]world.Object),
+		tiles:    make(map[world.Position][]uint32),
+		sessions: make(map[*session]struct{}),
+		done:     make(chan struct{}),
+	}
+	s.server.Logger = config.Logger
+	s.server.Handler = s.handle
+	s.server.Admission = config.Admission
+	s.generate()
+	go s.loop()
+	return s, nil
+}
+
+// Serve accepts connections on the given listener until Close is called.
+func (s *Server) Serve(l net.Listener) error {
+	return s.server.Serve(l)
+}
+
+// Listen listens on the given TCP address until Close is called.
+func (s *Server) Listen(address string) error {
+	return s.server.Listen(address)
+}
+
+// Close stops accepting connections and disconnects all players.
+func (s *Server) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+	err := s.server.Close()
+	s.lock.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for ss := range s.sessions {
+		sessions = append(sessions, ss)
+	}
+	s.lock.Unlock()
+	for _, ss := range sessions {
+		ss.close()
+	}
+	return err
+}
+
+// generate creates the floor, the surrounding walls, and randomly placed walls and items.
+func (s *Server) generate() {
+	r := rand.New(rand.NewSource(s.Seed))
+	floor := s.Assets.ID(FloorAsset)
+	wall := s.Assets.ID(WallAsset)
+	items := s.Assets.Items()
+	for z := 0; z < s.Depth; z++ {
+		for x := 0; x < s.Width; x++ {
+			s.place(s.newObject(floor, 1, false), world.Position{X: uint32(x), Y: 0, Z: uint32(z)})
+			edge := x == 0 || z == 0 || x == s.Width-1 || z == s.Depth-1
+			if edge || r.Intn(12) == 0 {
+				s.place(s.newObject(wall, 1, true), world.Position{X: uint32(x), Y: 1, Z: uint32(z)})
+			} else if len(items) > 0 && r.Intn(24) == 0 {
+				s.place(s.newObject(items[r.Intn(len(items))], 1, false), world.Position{X: uint32(x), Y: 1, Z: uint32(z)})
+			}
+		}
+	}
+}
+
+// newObject creates a new object using the given asset. The lock must be held or the server not yet running.
+func (s *Server) newObject(asset uint32, height uint8, opaque bool) world.Object {
+	s.nextID++
+	o := world.Object{
+		ID:          s.nextID,
+		TypeID:      uint8(data.ArchetypeItem),
+		AnimationID: asset,
+		Height:      height,
+		Width:       1,
+		Depth:       1,
+		Opaque:      opaque,
+	}
+	s.objects[o.ID] = o
+	return o
+}
+
+func (s *Server) place(o world.Object, p world.Position) {
+	s.tiles[p] = append(s.tiles[p], o.ID)
+}
+
+func (s *Server) remove(id uint32, p world.Position) {
+	ids := s.tiles[p]
+	for i, oid := range ids {
+		if oid == id {
+			s.tiles[p] = append(ids[:i:i], ids[i+1:]...)
+			return
+		}
+	}
+}
+
+// blocked returns whether the given position is outside the map or contains an opaque object.
+func (s *Server) blocked(p world.Position) bool {
+	if p.X >= uint32(s.Width) || p.Z >= uint32(s.Depth) || p.Y != 1 {
+		return true
+	}
+	for _, id := range s.tiles[p] {
+		if s.objects[id].Opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// spawn finds a free position for a new player, starting from the center of the map.
+func (s *Server) spawn() world.Position {
+	center := world.Position{X: uint32(s.Width / 2), Y: 1, Z: uint32(s.Depth / 2)}
+	for radius := 0; radius < s.Width+s.Depth; radius++ {
+		for dz := -radius; dz <= radius; dz++ {
+			for dx := -radius; dx <= radius; dx++ {
+				p := world.Position{X: uint32(int(center.X) + dx), Y: 1, Z: uint32(int(center.Z) + dz)}
+				if !s.blocked(p) {
+					return p
+				}
+			}
+		}
+	}
+	return center
+}
+
+// snapshot returns the complete world as visible to the given object. The lock must be held.
+func (s *Server) snapshot(viewTarget uint32) *world.Snapshot {
+	snap := world.NewSnapshot()
+	for p, ids := range s.tiles {
+		snap.Tiles[p] = world.Tile{
+			ObjectIDs: append([]uint32(nil), ids...),
+			R:         255,
+			G:         255,
+			B:         255,
+			Sky:       1,
+		}
+		for _, id := range ids {
+			snap.Objects[id] = s.objects[id]
+		}
+	}
+	snap.ViewTarget = viewTarget
+	snap.ViewHeight, snap.ViewWidth, snap.ViewDepth = mapHeight, uint8(min(s.Width, 255)), uint8(min(s.Depth, 255))
+	return snap
+}
+
+// loop sends world updates to all playing sessions every TickRate.
+func (s *Server) loop() {
+	ticker := time.NewTicker(s.TickRate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.lock.Lock()
+			s.tick++
+			for ss := range s.sessions {
+				if ss.objectID == 0 {
+					continue
+				}
+				for _, cmd := range ss.differ.Update(s.snapshot(ss.objectID)) {
+					ss.send(cmd)
+				}
+			}
+			s.lock.Unlock()
+		}
+	}
+}
+
+func (s *Server) logger() *slog.Logger {
+	if s.Logger == nil {
+		return slog.Default()
+	}
+	return s.Logger
+}
+
+// broadcast queues the given command for all playing sessions. The lock must be held.
+func (s *Server) broadcast(cmd network.Command) {
+	for ss := range s.sessions {
+		if ss.objectID != 0 {
+			ss.send(cmd)
+		}
+	}
+}
+
+func (s *Server) inspect(id uint32) (info data.ObjectInfo, ok bool) {
+	o, ok := s.objects[id]
+	if !ok {
+		return
+	}
+	name := fmt.Sprintf("Object %d", id)
+	if asset, exists := s.Assets.Get(o.AnimationID); exists {
+		name = asset.Name
+	}
+	return data.ObjectInfo{
+		Name:   name,
+		Weight: 1,
+		Count:  1,
+		Matter: data.SolidMatter,
+	}, true
+}
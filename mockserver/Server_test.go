@@ -0,0 +1,184 @@
+package mockserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chimera-rpg/go-common/bot"
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// testServer starts a Server on a local port and returns it along with its address.
+func testServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	network.RegisterCommands()
+	s, err := New(Config{
+		Seed:     1,
+		TickRate: 10 * time.Millisecond,
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() {
+		s.Close()
+	})
+	return s, l.Addr().String()
+}
+
+// received collects the commands a bot receives.
+type received struct {
+	lock sync.Mutex
+	cmds []network.Command
+}
+
+func (r *received) add(cmd network.Command) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cmds = append(r.cmds, cmd)
+}
+
+// wait waits for a received command that satisfies match.
+func (r *received) wait(t *testing.T, what string, match func(network.Command) bool) network.Command {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.lock.Lock()
+		i := slices.IndexFunc(r.cmds, match)
+		var cmd network.Command
+		if i >= 0 {
+			cmd = r.cmds[i]
+		}
+		r.lock.Unlock()
+		if i >= 0 {
+			return cmd
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("never received %s", what)
+	return nil
+}
+
+// runBot runs the bot until the test ends, returning the channel that receives the result of Run.
+func runBot(t *testing.T, b *bot.Bot) (*received, <-chan error) {
+	t.Helper()
+	r := &received{}
+	b.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	b.OnReceive = r.add
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		done <- b.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+	return r, done
+}
+
+func TestServerPlaintext(t *testing.T) {
+	_, address := testServer(t)
+	b := bot.New(bot.Config{
+		Address:   address,
+		User:      "tester",
+		Pass:      "anything",
+		Plaintext: true,
+		Interval:  10 * time.Millisecond,
+		Seed:      1,
+	}, bot.Chat{Chance: 1, Type: network.ChatMessage, Messages: []string{"hello"}})
+	r, _ := runBot(t, b)
+
+	r.wait(t, "handshake", func(cmd network.Command) bool {
+		hs, ok := cmd.(network.CommandHandshake)
+		return ok && hs.Version == network.Version && hs.Program == "chimera-mockserver"
+	})
+	r.wait(t, "welcome", func(cmd network.Command) bool {
+		c, ok := cmd.(network.CommandBasic)
+		return ok && c.Type == network.Okay && c.String == "Welcome, tester"
+	})
+	r.wait(t, "characters", func(cmd network.Command) bool {
+		c, ok := cmd.(network.CommandCharacter)
+		return ok && c.Type == network.QueryCharacters && slices.Equal(c.Characters, []string{"Mock"})
+	})
+	r.wait(t, "map", func(cmd network.Command) bool {
+		c, ok := cmd.(network.CommandMap)
+		return ok && c.Width == 32 && c.Depth == 32
+	})
+	r.wait(t, "chat echo", func(cmd network.Command) bool {
+		c, ok := cmd.(network.CommandMessage)
+		return ok && c.Type == network.ChatMessage && c.From == "tester" && c.Body == "hello" && c.FromObjectID != 0
+	})
+}
+
+func TestServerChallengeResponse(t *testing.T) {
+	s, address := testServer(t)
+	v, err := network.NewVerifier("secret", network.MinIterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Auth.Store.SetVerifier("registered", v); err != nil {
+		t.Fatal(err)
+	}
+
+	b := bot.New(bot.Config{
+		Address:  address,
+		User:     "registered",
+		Pass:     "secret",
+		Interval: 10 * time.Millisecond,
+	})
+	r, _ := runBot(t, b)
+	r.wait(t, "challenge", func(cmd network.Command) bool {
+		c, ok := cmd.(network.CommandAuth)
+		return ok && c.Type == network.AuthChallenge && c.Iterations == network.MinIterations
+	})
+	r.wait(t, "server proof", func(cmd network.Command) bool {
+		c, ok := cmd.(network.CommandAuth)
+		return ok && c.Type == network.AuthVerified
+	})
+	r.wait(t, "map", func(cmd network.Command) bool {
+		_, ok := cmd.(network.CommandMap)
+		return ok
+	})
+}
+
+func TestServerWrongPassword(t *testing.T) {
+	s, address := testServer(t)
+	v, err := network.NewVerifier("secret", network.MinIterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Auth.Store.SetVerifier("registered", v); err != nil {
+		t.Fatal(err)
+	}
+
+	b := bot.New(bot.Config{
+		Address:  address,
+		User:     "registered",
+		Pass:     "wrong",
+		Interval: 10 * time.Millisecond,
+	})
+	_, done := runBot(t, b)
+	select {
+	case err := <-done:
+		if !errors.Is(err, bot.ErrRejected) {
+			t.Fatalf("got %v, want %v", err, bot.ErrRejected)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("bot was never rejected")
+	}
+}
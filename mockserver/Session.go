@@ -0,0 +1,304 @@
+package mockserver
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/chimera-rpg/go-common/data"
+	"github.com/chimera-rpg/go-common/network"
+	"github.com/chimera-rpg/go-common/world"
+)
+
+// sendQueueSize is how many commands may wait to be sent to a session before it is disconnected for falling behind.
+const sendQueueSize = 1024
+
+// session is a single connected client. Commands are sent through a queue so that a slow client never blocks the server.
+type session struct {
+	server   *Server
+	conn     *network.Connection
+	sendLock sync.Mutex
+	queue    chan network.Command // Commands waiting to be sent by write.
+	done     chan struct{}        // Closed when the session ends.
+	dropOnce sync.Once
+	user     string
+	objectID uint32 // The player's object, or 0 if not playing. Guarded by the server lock.
+	position world.Position
+	differ   world.Differ
+	auth     *network.AuthSession // The challenge-response in progress, if any.
+}
+
+// send queues the command to be sent. It never blocks, closing the session instead if its queue is full.
+func (ss *session) send(cmd network.Command) {
+	select {
+	case ss.queue <- cmd:
+	case <-ss.done:
+	default:
+		ss.drop()
+	}
+}
+
+// drop disconnects a session that has fallen behind. The underlying net.Conn is closed directly so that a write blocked on the client is released.
+func (ss *session) drop() {
+	ss.dropOnce.Do(func() {
+		ss.server.logger().Warn("dropping slow session", slog.Uint64("conn", ss.conn.ID), slog.Int("queued", len(ss.queue)))
+		ss.conn.Conn.Close()
+	})
+}
+
+// write sends queued commands until the session ends.
+func (ss *session) write() {
+	for {
+		select {
+		case cmd := <-ss.queue:
+			ss.sendLock.Lock()
+			if ss.conn.IsConnected {
+				ss.conn.Send(cmd)
+			}
+			ss.sendLock.Unlock()
+		case <-ss.done:
+			return
+		}
+	}
+}
+
+func (ss *session) close() {
+	go func() {
+		ss.sendLock.Lock()
+		defer ss.sendLock.Unlock()
+		ss.conn.Close()
+	}()
+}
+
+// handle runs a session for the given connection until it is closed.
+func (s *Server) handle(c *network.Connection) {
+	ss := &session{
+		server: s,
+		conn:   c,
+		queue:  make(chan network.Command, sendQueueSize),
+		done:   make(chan struct{}),
+	}
+	s.lock.Lock()
+	s.sessions[ss] = struct{}{}
+	s.lock.Unlock()
+	defer s.leave(ss)
+	defer close(ss.done)
+
+	go ss.write()
+	go c.LoopCmd()
+	for {
+		select {
+		case <-c.ClosedChan:
+			return
+		case cmd := <-c.CmdChan:
+			if !ss.handle(cmd) {
+				ss.close()
+			}
+		}
+	}
+}
+
+// leave removes the session and its player object.
+func (s *Server) leave(ss *session) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.sessions, ss)
+	if ss.objectID != 0 {
+		s.remove(ss.objectID, ss.position)
+		delete(s.objects, ss.objectID)
+		ss.objectID = 0
+	}
+}
+
+// handle processes a single command from the client. It returns false if the session should be closed.
+func (ss *session) handle(cmd network.Command) bool {
+	s := ss.server
+	switch c := cmd.(type) {
+	case network.CommandBasic:
+		if c.Type == network.Cya {
+			return false
+		}
+	case network.CommandHandshake:
+		ss.send(network.CommandHandshake{
+			Version: network.Version,
+			Program: "chimera-mockserver",
+		})
+		features := network.CommandFeatures{}
+		features.AnimationsConfig.TileWidth = 32
+		features.AnimationsConfig.TileHeight = 32
+		features.AnimationsConfig.YStep.Y = -8
+		ss.send(features)
+	case network.CommandLogin:
+		switch c.Type {
+		case network.Register:
+			if c.Verifier.Salt != nil {
+				if err := s.Auth.Register(c); err != nil {
+					ss.send(network.CommandBasic{Type: network.Reject, String: err.Error()})
+					return true
+				}
+			}
+			ss.welcome(c.User)
+		case network.Login:
+			if _, ok := s.Auth.Store.Verifier(c.User); ok {
+				auth, challenge, err := s.Auth.Challenge(c)
+				if err != nil {
+					ss.send(network.CommandBasic{Type: network.Reject, String: err.Error()})
+					return true
+				}
+				ss.auth = auth
+				ss.send(challenge)
+				return true
+			}
+			ss.welcome(c.User)
+		default:
+			ss.send(network.CommandBasic{Type: network.Okay})
+		}
+	case network.CommandAuth:
+		if ss.auth == nil {
+			return true
+		}
+		auth := ss.auth
+		ss.auth = nil
+		verified, err := auth.Verify(c)
+		if err != nil {
+			ss.send(network.CommandBasic{Type: network.Reject, String: err.Error()})
+			return true
+		}
+		ss.send(verified)
+		ss.welcome(auth.User)
+	case network.CommandCharacter:
+		switch c.Type {
+		case network.ChooseCharacter:
+			ss.join()
+		case network.CreateCharacter, network.DeleteCharacter:
+			ss.send(network.CommandBasic{Type: network.Okay})
+			ss.send(network.CommandCharacter{
+				Type:       network.QueryCharacters,
+				Characters: s.Characters,
+			})
+		default:
+			ss.send(network.CommandCharacter{Type: c.Type})
+		}
+	case network.CommandRejoin:
+		s.lock.Lock()
+		if ss.objectID != 0 {
+			snap := ss.differ.Snapshot(s.snapshot(ss.objectID))
+			snap.Map = ss.mapCommand()
+			snap.Tick = s.tick
+			ss.send(snap)
+		}
+		s.lock.Unlock()
+	case network.CommandGraphics:
+		if asset, ok := s.Assets.Get(c.GraphicsID); ok && c.Type == network.Get {
+			ss.send(network.CommandGraphics{
+				Type:       network.Set,
+				GraphicsID: asset.ID,
+				DataType:   network.GraphicsPng,
+				Data:       asset.Data,
+			})
+		} else {
+			ss.send(network.CommandGraphics{Type: network.Nokay, GraphicsID: c.GraphicsID})
+		}
+	case network.CommandAnimation:
+		if _, ok := s.Assets.Get(c.AnimationID); ok && c.Type == network.Get {
+			ss.send(s.Assets.Animation(c.AnimationID))
+		}
+	case network.CommandCmd:
+		ss.move(c.Cmd, c.Input)
+	case network.CommandMessage:
+		s.lock.Lock()
+		s.broadcast(network.CommandMessage{
+			Type:         c.Type,
+			From:         ss.user,
+			FromObjectID: ss.objectID,
+			Title:        c.Title,
+			Body:         c.Body,
+		})
+		s.lock.Unlock()
+	case network.CommandInspect:
+		s.lock.Lock()
+		info, ok := s.inspect(c.ObjectID)
+		s.lock.Unlock()
+		if ok {
+			ss.send(network.CommandObject{
+				ObjectID: c.ObjectID,
+				Payload:  network.CommandObjectPayloadInfo{Info: []data.ObjectInfo{info}},
+			})
+		}
+	}
+	return true
+}
+
+// welcome logs in the given user and offers the characters.
+func (ss *session) welcome(user string) {
+	ss.user = user
+	ss.send(network.CommandBasic{Type: network.Okay, String: "Welcome, " + user})
+	ss.send(network.CommandCharacter{
+		Type:       network.QueryCharacters,
+		Characters: ss.server.Characters,
+	})
+}
+
+func (ss *session) mapCommand() network.CommandMap {
+	return network.CommandMap{
+		Type:         network.Travel,
+		MapID:        1,
+		Name:         "Mock",
+		Height:       mapHeight,
+		Width:        ss.server.Width,
+		Depth:        ss.server.Depth,
+		Outdoor:      true,
+		OutdoorRed:   255,
+		OutdoorGreen: 255,
+		OutdoorBlue:  255,
+		AmbientRed:   128,
+		AmbientGreen: 128,
+		AmbientBlue:  128,
+	}
+}
+
+// join places the player on the map. The world itself is sent by the next tick.
+func (ss *session) join() {
+	s := ss.server
+	for _, asset := range s.Assets.List {
+		ss.send(s.Assets.Animation(asset.ID))
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if ss.objectID == 0 {
+		o := s.newObject(s.Assets.ID(PlayerAsset), 1, false)
+		o.TypeID = uint8(data.ArchetypePC)
+		o.Reach = 1
+		s.objects[o.ID] = o
+		ss.position = s.spawn()
+		s.place(o, ss.position)
+		ss.objectID = o.ID
+	}
+	ss.differ.Reset()
+	cmd := ss.mapCommand()
+	cmd.Tick = s.tick
+	ss.send(cmd)
+}
+
+// move attempts to move the player in the given direction and acknowledges the input.
+func (ss *session) move(cmd int, input uint32) {
+	s := ss.server
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if ss.objectID == 0 {
+		return
+	}
+	if p, ok := world.DirectionalMove(ss.position, cmd); ok && !s.blocked(p) {
+		s.remove(ss.objectID, ss.position)
+		ss.position = p
+		s.place(s.objects[ss.objectID], p)
+	}
+	if input != 0 {
+		ss.send(network.CommandInputAck{
+			Input: input,
+			X:     ss.position.X,
+			Y:     ss.position.Y,
+			Z:     ss.position.Z,
+			Tick:  s.tick,
+		})
+	}
+}
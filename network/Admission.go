@@ -0,0 +1,331 @@
+package network
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrBanned is returned by Admitter when the address is banned.
+	ErrBanned = errors.New("address banned")
+	// ErrTooManyConnections is returned by Admitter when the address has too many open connections.
+	ErrTooManyConnections = errors.New("too many connections from address")
+	// ErrRateLimited is returned by Admitter when the address connects too often.
+	ErrRateLimited = errors.New("connecting too often")
+	// ErrUnknownAddress is returned by Admitter when the IP address of a connection cannot be determined.
+	ErrUnknownAddress = errors.New("unknown address")
+)
+
+// DefaultIPv6Prefix is the prefix length that Admitter groups IPv6 addresses by, as a single host is commonly given a whole /64.
+const DefaultIPv6Prefix = 64
+
+// Admission decides whether the Server serves an accepted connection. It is consulted before the TLS handshake, so refused hosts cost as little as possible.
+type Admission interface {
+	// Admit returns an error if the connection from the given address should be refused.
+	Admit(addr net.Addr) error
+	// Release is called once an admitted connection's Handler has returned.
+	Release(addr net.Addr)
+}
+
+// addrIP returns the IP address of the given network address.
+func addrIP(addr net.Addr) (netip.Addr, bool) {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		ip, ok := netip.AddrFromSlice(tcp.IP)
+		return ip.Unmap(), ok
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	return ap.Addr().Unmap(), err == nil
+}
+
+// Admitter is an Admission with a ban list, a limit of concurrent connections per address, and a connection rate limit per address. IPv6 addresses are limited together with the rest of their IPv6Prefix, so that a host cannot evade the limits by using many addresses of its network. Connections whose IP address cannot be determined are refused.
+type Admitter struct {
+	Bans          *BanList // Bans, if set, refuses banned addresses.
+	MaxPerAddress int      // MaxPerAddress limits the concurrent connections of each address. Unlimited if zero.
+	Rate          float64  // Rate limits each address to this many new connections per second on average. Unlimited if zero.
+	Burst         int      // Burst is how many connections an address may make at once before Rate applies. Defaults to 1.
+	IPv6Prefix    int      // IPv6Prefix is the prefix length that IPv6 addresses are grouped by for the limits. Defaults to DefaultIPv6Prefix, and 128 limits each address separately.
+	lock          sync.Mutex
+	addresses     map[netip.Addr]*admitted
+	lastSweep     time.Time
+}
+
+// admitted is the state of a single address.
+type admitted struct {
+	active int
+	tokens float64
+	last   time.Time
+}
+
+// Admit refuses unknown addresses, banned addresses, addresses at MaxPerAddress, and addresses exceeding Rate.
+func (a *Admitter) Admit(addr net.Addr) error {
+	ip, ok := addrIP(addr)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownAddress, addr)
+	}
+	if a.Bans != nil {
+		if ban, banned := a.Bans.Banned(ip); banned {
+			return fmt.Errorf("%w: %s", ErrBanned, ban.Reason)
+		}
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	now := time.Now()
+	if a.addresses == nil {
+		a.addresses = make(map[netip.Addr]*admitted)
+	}
+	a.sweep(now)
+	burst := float64(max(a.Burst, 1))
+	key := a.key(ip)
+	s, ok := a.addresses[key]
+	if !ok {
+		s = &admitted{tokens: burst, last: now}
+		a.addresses[key] = s
+	}
+	if a.MaxPerAddress > 0 && s.active >= a.MaxPerAddress {
+		return ErrTooManyConnections
+	}
+	if a.Rate > 0 {
+		s.tokens = min(burst, s.tokens+now.Sub(s.last).Seconds()*a.Rate)
+		s.last = now
+		if s.tokens < 1 {
+			return ErrRateLimited
+		}
+		s.tokens--
+	}
+	s.active++
+	return nil
+}
+
+// Release ends an admitted connection of the given address.
+func (a *Admitter) Release(addr net.Addr) {
+	ip, ok := addrIP(addr)
+	if !ok {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if s, ok := a.addresses[a.key(ip)]; ok && s.active > 0 {
+		s.active--
+	}
+}
+
+// Active returns the number of admitted connections of the given IP address, or of its IPv6Prefix, that have not been released.
+func (a *Admitter) Active(ip netip.Addr) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if s, ok := a.addresses[a.key(ip.Unmap())]; ok {
+		return s.active
+	}
+	return 0
+}
+
+// key returns the address that the limits of the given IP address are kept under.
+func (a *Admitter) key(ip netip.Addr) netip.Addr {
+	if !ip.Is6() {
+		return ip
+	}
+	bits := a.IPv6Prefix
+	if bits <= 0 || bits > 128 {
+		bits = DefaultIPv6Prefix
+	}
+	return netip.PrefixFrom(ip.WithZone(""), bits).Masked().Addr()
+}
+
+// sweep forgets idle addresses that have recovered their full burst, at most once per second. The lock must be held.
+func (a *Admitter) sweep(now time.Time) {
+	if now.Sub(a.lastSweep) < time.Second {
+		return
+	}
+	a.lastSweep = now
+	burst := float64(max(a.Burst, 1))
+	for ip, s := range a.addresses {
+		if s.active == 0 && (a.Rate <= 0 || s.tokens+now.Sub(s.last).Seconds()*a.Rate >= burst) {
+			delete(a.addresses, ip)
+		}
+	}
+}
+
+// Ban is a single banned IP address or CIDR range.
+type Ban struct {
+	Prefix  netip.Prefix
+	Expires time.Time // Expires is when the ban ends. The ban is permanent if zero.
+	Reason  string
+}
+
+// BanList is a list of banned IP addresses and CIDR ranges, saved to Path on every change.
+//
+// The file contains one ban per line: the address or range, the RFC 3339 expiry or "-" if permanent, and an optional reason.
+type BanList struct {
+	Path string
+	lock sync.Mutex
+	bans map[netip.Prefix]Ban
+}
+
+// LoadBanList loads the BanList from the given path. A missing file is treated as empty. If path is empty, bans are not persisted.
+func LoadBanList(path string) (*BanList, error) {
+	l := &BanList{
+		Path: path,
+		bans: make(map[netip.Prefix]Ban),
+	}
+	if path == "" {
+		return l, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 3)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: expected address and expiry", path, line)
+		}
+		prefix, err := ParsePrefix(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		ban := Ban{Prefix: prefix}
+		if fields[1] != "-" {
+			if ban.Expires, err = time.Parse(time.RFC3339, fields[1]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+		}
+		if len(fields) == 3 {
+			ban.Reason = fields[2]
+		}
+		l.bans[prefix] = ban
+	}
+	return l, scanner.Err()
+}
+
+// ParsePrefix parses an IP address or CIDR range. A single address becomes a range containing only that address.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		return p.Masked(), err
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ip = ip.Unmap()
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+// Ban bans the given IP address or CIDR range for the given duration, or permanently if zero. Bans are checked when connections are admitted, so connections that are already open are not closed.
+func (l *BanList) Ban(addressOrRange string, duration time.Duration, reason string) error {
+	prefix, err := ParsePrefix(addressOrRange)
+	if err != nil {
+		return err
+	}
+	ban := Ban{
+		Prefix: prefix,
+		Reason: strings.ReplaceAll(reason, "\n", " "),
+	}
+	if duration > 0 {
+		ban.Expires = time.Now().Add(duration).UTC().Truncate(time.Second)
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.bans == nil {
+		l.bans = make(map[netip.Prefix]Ban)
+	}
+	l.bans[prefix] = ban
+	return l.save()
+}
+
+// Unban removes the ban of the given IP address or CIDR range.
+func (l *BanList) Unban(addressOrRange string) error {
+	prefix, err := ParsePrefix(addressOrRange)
+	if err != nil {
+		return err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.bans, prefix)
+	return l.save()
+}
+
+// Banned returns the ban covering the given IP address, if any.
+func (l *BanList) Banned(ip netip.Addr) (Ban, bool) {
+	ip = ip.Unmap()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	for _, ban := range l.bans {
+		if ban.Prefix.Contains(ip) && (ban.Expires.IsZero() || now.Before(ban.Expires)) {
+			return ban, true
+		}
+	}
+	return Ban{}, false
+}
+
+// List returns all bans, including expired ones that have not been purged, sorted by address.
+func (l *BanList) List() []Ban {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.sorted()
+}
+
+// Purge removes all expired bans.
+func (l *BanList) Purge() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	for prefix, ban := range l.bans {
+		if !ban.Expires.IsZero() && !now.Before(ban.Expires) {
+			delete(l.bans, prefix)
+		}
+	}
+	return l.save()
+}
+
+// sorted returns the bans sorted by address. The lock must be held.
+func (l *BanList) sorted() []Ban {
+	bans := make([]Ban, 0, len(l.bans))
+	for _, ban := range l.bans {
+		bans = append(bans, ban)
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		if c := bans[i].Prefix.Addr().Compare(bans[j].Prefix.Addr()); c != 0 {
+			return c < 0
+		}
+		return bans[i].Prefix.Bits() < bans[j].Prefix.Bits()
+	})
+	return bans
+}
+
+// save atomically writes the bans to Path. The lock must be held.
+func (l *BanList) save() error {
+	if l.Path == "" {
+		return nil
+	}
+	var b strings.Builder
+	for _, ban := range l.sorted() {
+		expires := "-"
+		if !ban.Expires.IsZero() {
+			expires = ban.Expires.Format(time.RFC3339)
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", ban.Prefix, expires, ban.Reason)
+	}
+	tmp := l.Path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.Path)
+}
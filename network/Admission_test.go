@@ -0,0 +1,186 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func tcpAddr(s string) net.Addr {
+	return net.TCPAddrFromAddrPort(netip.MustParseAddrPort(s))
+}
+
+func TestAdmitterMaxPerAddress(t *testing.T) {
+	a := &Admitter{MaxPerAddress: 2}
+	for i := 0; i < 2; i++ {
+		if err := a.Admit(tcpAddr("10.0.0.1:1000")); err != nil {
+			t.Fatalf("connection %d refused: %v", i, err)
+		}
+	}
+	if err := a.Admit(tcpAddr("10.0.0.1:1000")); !errors.Is(err, ErrTooManyConnections) {
+		t.Fatalf("third connection got %v, want %v", err, ErrTooManyConnections)
+	}
+	if err := a.Admit(tcpAddr("10.0.0.2:1000")); err != nil {
+		t.Fatalf("other address refused: %v", err)
+	}
+	a.Release(tcpAddr("10.0.0.1:1000"))
+	if got := a.Active(netip.MustParseAddr("10.0.0.1")); got != 1 {
+		t.Fatalf("%d active after release, want 1", got)
+	}
+	if err := a.Admit(tcpAddr("10.0.0.1:1000")); err != nil {
+		t.Fatalf("connection after release refused: %v", err)
+	}
+}
+
+func TestAdmitterRate(t *testing.T) {
+	a := &Admitter{Rate: 0.001, Burst: 2}
+	for i := 0; i < 2; i++ {
+		if err := a.Admit(tcpAddr("10.0.0.1:1000")); err != nil {
+			t.Fatalf("connection %d refused: %v", i, err)
+		}
+		a.Release(tcpAddr("10.0.0.1:1000"))
+	}
+	if err := a.Admit(tcpAddr("10.0.0.1:1000")); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("connection beyond burst got %v, want %v", err, ErrRateLimited)
+	}
+}
+
+func TestAdmitterIPv6Prefix(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    int
+		first     string
+		second    string
+		wantLimit bool // Whether the second address shares the limit of the first.
+	}{
+		{"same /64 by default", 0, "[2001:db8::1]:1000", "[2001:db8::ffff:1]:1000", true},
+		{"other /64 by default", 0, "[2001:db8::1]:1000", "[2001:db8:0:1::1]:1000", false},
+		{"same /48", 48, "[2001:db8::1]:1000", "[2001:db8:0:1::1]:1000", true},
+		{"each address", 128, "[2001:db8::1]:1000", "[2001:db8::2]:1000", false},
+		{"IPv4 is not grouped", 0, "10.0.0.1:1000", "10.0.0.2:1000", false},
+		{"IPv4-mapped IPv6 is IPv4", 0, "[::ffff:10.0.0.1]:1000", "10.0.0.1:1000", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Admitter{MaxPerAddress: 1, IPv6Prefix: tt.prefix}
+			if err := a.Admit(tcpAddr(tt.first)); err != nil {
+				t.Fatalf("first address refused: %v", err)
+			}
+			err := a.Admit(tcpAddr(tt.second))
+			if limited := errors.Is(err, ErrTooManyConnections); limited != tt.wantLimit {
+				t.Fatalf("second address got %v, want limited %v", err, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestAdmitterUnknownAddress(t *testing.T) {
+	a := &Admitter{}
+	if err := a.Admit(&net.UnixAddr{Name: "/tmp/socket", Net: "unix"}); !errors.Is(err, ErrUnknownAddress) {
+		t.Fatalf("got %v, want %v", err, ErrUnknownAddress)
+	}
+}
+
+func TestAdmitterBans(t *testing.T) {
+	bans, err := LoadBanList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bans.Ban("10.1.0.0/16", 0, "range"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bans.Ban("2001:db8::1", time.Hour, "address"); err != nil {
+		t.Fatal(err)
+	}
+	expired := netip.MustParsePrefix("10.2.0.0/16")
+	bans.bans[expired] = Ban{Prefix: expired, Expires: time.Now().Add(-time.Second)}
+
+	a := &Admitter{Bans: bans}
+	tests := []struct {
+		addr       string
+		wantBanned bool
+	}{
+		{"10.1.2.3:1000", true},
+		{"[::ffff:10.1.2.3]:1000", true},
+		{"10.3.0.1:1000", false},
+		{"10.2.0.1:1000", false},
+		{"[2001:db8::1]:1000", true},
+		{"[2001:db8::2]:1000", false},
+	}
+	for _, tt := range tests {
+		err := a.Admit(tcpAddr(tt.addr))
+		if banned := errors.Is(err, ErrBanned); banned != tt.wantBanned {
+			t.Errorf("%s: got %v, want banned %v", tt.addr, err, tt.wantBanned)
+		}
+	}
+}
+
+func TestBanListZeroValue(t *testing.T) {
+	var bans BanList
+	if err := bans.Ban("10.0.0.1", 0, "zero value"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bans.Banned(netip.MustParseAddr("10.0.0.1")); !ok {
+		t.Fatal("address not banned")
+	}
+}
+
+func TestBanListSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans")
+	bans, err := LoadBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bans.Ban("10.1.0.0/16", 0, "a range\nwith a newline"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bans.Ban("2001:db8::1", time.Hour, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := bans.Ban("10.0.0.1", time.Hour, "address"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bans.Unban("10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loaded.List(), bans.List(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("loaded %+v, want %+v", got, want)
+	}
+	if len(loaded.List()) != 2 {
+		t.Fatalf("loaded %d bans, want 2", len(loaded.List()))
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"10.0.0.1", "10.0.0.1/32", false},
+		{"10.0.0.1/8", "10.0.0.0/8", false},
+		{"::ffff:10.0.0.1", "10.0.0.1/32", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"2001:db8::1/64", "2001:db8::/64", false},
+		{"not an address", "", true},
+		{"10.0.0.1/33", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePrefix(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
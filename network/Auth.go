@@ -0,0 +1,368 @@
+package network
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// DefaultIterations is the number of PBKDF2 iterations used for new Verifiers.
+const DefaultIterations = 100000
+
+// MinIterations is the fewest PBKDF2 iterations that an Authenticator accepts for a registered Verifier.
+const MinIterations = 4096
+
+// MaxIterations is the most PBKDF2 iterations that an Authenticator accepts for a registered Verifier or an AuthClient will compute for a challenge. It keeps a server from making clients do unbounded work.
+const MaxIterations = 10000000
+
+// The lengths of our salts and nonces.
+const (
+	saltLength  = 16
+	nonceLength = 24
+)
+
+var (
+	// ErrAuthFailed is returned when a challenge-response proof or signature is wrong.
+	ErrAuthFailed = errors.New("authentication failed")
+	// ErrAuthNonce is returned when a challenge-response step does not carry the expected nonce or its AuthSession has already been used.
+	ErrAuthNonce = errors.New("authentication nonce mismatch")
+	// ErrAuthIterations is returned when a challenge asks for fewer than MinIterations or more than MaxIterations.
+	ErrAuthIterations = errors.New("authentication iterations out of range")
+	// ErrInvalidVerifier is returned when registering with a malformed or weak Verifier.
+	ErrInvalidVerifier = errors.New("invalid verifier")
+	// ErrUserExists is returned when registering a user that already exists.
+	ErrUserExists = errors.New("user exists")
+)
+
+// Verifier is the record of a password that the server stores in its place, following SCRAM (RFC 5802) with PBKDF2-SHA256. It allows the server to check a client's proof without the password being sent, but cannot itself be used to log in.
+type Verifier struct {
+	Salt       []byte
+	Iterations int
+	StoredKey  []byte
+	ServerKey  []byte
+}
+
+// NewVerifier returns a Verifier for the given password with a random salt.
+func NewVerifier(password string, iterations int) (v Verifier, err error) {
+	v.Salt = make([]byte, saltLength)
+	if _, err = rand.Read(v.Salt); err != nil {
+		return
+	}
+	v.Iterations = iterations
+	salted, err := saltPassword(password, v.Salt, iterations)
+	if err != nil {
+		return
+	}
+	v.StoredKey, v.ServerKey = verifierKeys(salted)
+	return
+}
+
+// Valid returns whether the Verifier is well-formed and uses between MinIterations and MaxIterations.
+func (v Verifier) Valid() bool {
+	return len(v.Salt) > 0 && validIterations(v.Iterations) && len(v.StoredKey) == sha256.Size && len(v.ServerKey) == sha256.Size
+}
+
+// Check returns whether the given password matches the Verifier. This is for servers that receive a password directly, such as from a plaintext CommandLogin.
+func (v Verifier) Check(password string) bool {
+	salted, err := saltPassword(password, v.Salt, v.Iterations)
+	if err != nil {
+		return false
+	}
+	storedKey, _ := verifierKeys(salted)
+	return subtle.ConstantTimeCompare(storedKey, v.StoredKey) == 1
+}
+
+func saltPassword(password string, salt []byte, iterations int) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, password, salt, iterations, sha256.Size)
+}
+
+func verifierKeys(salted []byte) (storedKey, serverKey []byte) {
+	h := sha256.Sum256(clientKey(salted))
+	return h[:], mac(salted, []byte("Server Key"))
+}
+
+func clientKey(salted []byte) []byte {
+	return mac(salted, []byte("Client Key"))
+}
+
+func mac(key []byte, parts ...[]byte) []byte {
+	h := hmac.New(sha256.New, key)
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+func validIterations(n int) bool {
+	return n >= MinIterations && n <= MaxIterations
+}
+
+func xor(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+func newNonce() ([]byte, error) {
+	nonce := make([]byte, nonceLength)
+	_, err := rand.Read(nonce)
+	return nonce, err
+}
+
+// authMessage returns the message that both sides sign, binding the proof to the user and the whole exchange.
+func authMessage(user string, clientNonce []byte, challenge CommandAuth) []byte {
+	var b bytes.Buffer
+	b.WriteString(user)
+	b.WriteByte(0)
+	b.Write(clientNonce)
+	b.Write(challenge.Salt)
+	b.WriteString(strconv.Itoa(challenge.Iterations))
+	b.Write(challenge.Nonce)
+	return b.Bytes()
+}
+
+// AuthClient performs the client side of a challenge-response login.
+type AuthClient struct {
+	User        string
+	Pass        string
+	clientNonce []byte
+	serverKey   []byte
+	message     []byte
+	verified    bool
+}
+
+// NewAuthClient returns an AuthClient for the given user and password.
+func NewAuthClient(user, pass string) *AuthClient {
+	return &AuthClient{
+		User: user,
+		Pass: pass,
+	}
+}
+
+// Start returns the CommandLogin that starts a challenge-response of the given type, Login or Delete.
+func (a *AuthClient) Start(loginType uint8) (cmd CommandLogin, err error) {
+	if a.clientNonce, err = newNonce(); err != nil {
+		return
+	}
+	return CommandLogin{
+		Type:  loginType,
+		User:  a.User,
+		Nonce: a.clientNonce,
+	}, nil
+}
+
+// Register returns the CommandLogin that registers the user with a Verifier of the password.
+func (a *AuthClient) Register(email string) (cmd CommandLogin, err error) {
+	v, err := NewVerifier(a.Pass, DefaultIterations)
+	if err != nil {
+		return
+	}
+	return CommandLogin{
+		Type:     Register,
+		User:     a.User,
+		Email:    email,
+		Verifier: v,
+	}, nil
+}
+
+// Respond returns the AuthProof answering the server's AuthChallenge. Challenges outside of MinIterations and MaxIterations are refused.
+func (a *AuthClient) Respond(challenge CommandAuth) (cmd CommandAuth, err error) {
+	if challenge.Type != AuthChallenge || a.clientNonce == nil || !bytes.HasPrefix(challenge.Nonce, a.clientNonce) || len(challenge.Nonce) <= len(a.clientNonce) {
+		return cmd, ErrAuthNonce
+	}
+	if !validIterations(challenge.Iterations) {
+		return cmd, ErrAuthIterations
+	}
+	salted, err := saltPassword(a.Pass, challenge.Salt, challenge.Iterations)
+	if err != nil {
+		return
+	}
+	storedKey, serverKey := verifierKeys(salted)
+	a.serverKey = serverKey
+	a.message = authMessage(a.User, a.clientNonce, challenge)
+	return CommandAuth{
+		Type:  AuthProof,
+		Nonce: challenge.Nonce,
+		Proof: xor(clientKey(salted), mac(storedKey, a.message)),
+	}, nil
+}
+
+// Verify checks the server's AuthVerified signature, proving that the server holds the user's Verifier.
+func (a *AuthClient) Verify(verified CommandAuth) error {
+	if verified.Type != AuthVerified || a.message == nil {
+		return ErrAuthFailed
+	}
+	if !hmac.Equal(verified.Proof, mac(a.serverKey, a.message)) {
+		return ErrAuthFailed
+	}
+	a.verified = true
+	return nil
+}
+
+// Verified returns whether the server's AuthVerified signature has been checked by Verify. A login should not be treated as successful until it has.
+func (a *AuthClient) Verified() bool {
+	return a.verified
+}
+
+// VerifierStore is the server-side storage of users' Verifiers.
+type VerifierStore interface {
+	Verifier(user string) (Verifier, bool)
+	SetVerifier(user string, v Verifier) error
+	CreateVerifier(user string, v Verifier) error // CreateVerifier atomically sets the Verifier of a new user, failing with ErrUserExists if the user exists.
+	DeleteVerifier(user string) error
+}
+
+// MemoryVerifierStore is a VerifierStore that keeps Verifiers in memory.
+type MemoryVerifierStore struct {
+	lock      sync.Mutex
+	verifiers map[string]Verifier
+}
+
+// NewMemoryVerifierStore returns an empty MemoryVerifierStore.
+func NewMemoryVerifierStore() *MemoryVerifierStore {
+	return &MemoryVerifierStore{
+		verifiers: make(map[string]Verifier),
+	}
+}
+
+// Verifier returns the Verifier of the given user.
+func (s *MemoryVerifierStore) Verifier(user string) (Verifier, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	v, ok := s.verifiers[user]
+	return v, ok
+}
+
+// SetVerifier sets the Verifier of the given user.
+func (s *MemoryVerifierStore) SetVerifier(user string, v Verifier) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.verifiers[user] = v
+	return nil
+}
+
+// CreateVerifier sets the Verifier of the given user unless the user exists.
+func (s *MemoryVerifierStore) CreateVerifier(user string, v Verifier) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.verifiers[user]; ok {
+		return ErrUserExists
+	}
+	s.verifiers[user] = v
+	return nil
+}
+
+// DeleteVerifier removes the given user.
+func (s *MemoryVerifierStore) DeleteVerifier(user string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.verifiers, user)
+	return nil
+}
+
+// Authenticator performs the server side of challenge-response logins against a VerifierStore.
+type Authenticator struct {
+	Store      VerifierStore
+	OnVerified func(s *AuthSession) error // OnVerified, if set, is called when a client's proof is accepted, such as to cancel a scheduled deletion on Login. Verify fails with its error, if any.
+	secret     []byte                     // secret is used to derive challenges for unknown users so that they look the same as known ones.
+}
+
+// NewAuthenticator returns an Authenticator using the given store.
+func NewAuthenticator(store VerifierStore) (*Authenticator, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &Authenticator{
+		Store:  store,
+		secret: secret,
+	}, nil
+}
+
+// Register stores the Verifier of a Register CommandLogin.
+func (a *Authenticator) Register(cmd CommandLogin) error {
+	if cmd.User == "" || !cmd.Verifier.Valid() {
+		return ErrInvalidVerifier
+	}
+	return a.Store.CreateVerifier(cmd.User, cmd.Verifier)
+}
+
+// Challenge starts the challenge-response for a Login or Delete CommandLogin with a Nonce. The returned AuthChallenge is sent to the client and the AuthSession kept to verify its reply. Unknown users receive a plausible challenge that can never be answered.
+func (a *Authenticator) Challenge(cmd CommandLogin) (*AuthSession, CommandAuth, error) {
+	if len(cmd.Nonce) == 0 {
+		return nil, CommandAuth{}, ErrAuthNonce
+	}
+	serverNonce, err := newNonce()
+	if err != nil {
+		return nil, CommandAuth{}, err
+	}
+	v, ok := a.Store.Verifier(cmd.User)
+	if !ok {
+		v = Verifier{
+			Salt:       mac(a.secret, []byte("salt"), []byte(cmd.User))[:saltLength],
+			Iterations: DefaultIterations,
+		}
+	}
+	challenge := CommandAuth{
+		Type:       AuthChallenge,
+		Salt:       v.Salt,
+		Iterations: v.Iterations,
+		Nonce:      append(append([]byte{}, cmd.Nonce...), serverNonce...),
+	}
+	return &AuthSession{
+		LoginType: cmd.Type,
+		User:      cmd.User,
+		auth:      a,
+		known:     ok,
+		verifier:  v,
+		nonce:     challenge.Nonce,
+		message:   authMessage(cmd.User, cmd.Nonce, challenge),
+	}, challenge, nil
+}
+
+// AuthSession is a single challenge-response in progress. It can only be verified once.
+type AuthSession struct {
+	LoginType uint8  // LoginType is the Type of the CommandLogin that started the session.
+	User      string // User is the user being authenticated.
+	auth      *Authenticator
+	known     bool
+	verifier  Verifier
+	nonce     []byte
+	message   []byte
+	used      bool
+}
+
+// Verify checks the client's AuthProof. On success, the returned AuthVerified should be sent to the client so that it can verify the server in turn.
+func (s *AuthSession) Verify(cmd CommandAuth) (CommandAuth, error) {
+	if s.used || cmd.Type != AuthProof || !bytes.Equal(cmd.Nonce, s.nonce) {
+		return CommandAuth{}, ErrAuthNonce
+	}
+	// A failed proof also uses up the session so that each challenge allows a single guess.
+	s.used = true
+	signature := mac(s.verifier.StoredKey, s.message)
+	if !s.known || len(cmd.Proof) != len(signature) {
+		return CommandAuth{}, ErrAuthFailed
+	}
+	key := sha256.Sum256(xor(cmd.Proof, signature))
+	if subtle.ConstantTimeCompare(key[:], s.verifier.StoredKey) != 1 {
+		return CommandAuth{}, ErrAuthFailed
+	}
+	if s.auth.OnVerified != nil {
+		if err := s.auth.OnVerified(s); err != nil {
+			return CommandAuth{}, err
+		}
+	}
+	return CommandAuth{
+		Type:  AuthVerified,
+		Nonce: s.nonce,
+		Proof: mac(s.verifier.ServerKey, s.message),
+	}, nil
+}
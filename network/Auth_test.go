@@ -0,0 +1,114 @@
+package network
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestAuthenticatorRegisterOnce(t *testing.T) {
+	a, err := NewAuthenticator(NewMemoryVerifierStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVerifier("password", MinIterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- a.Register(CommandLogin{Type: Register, User: "user", Verifier: v})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	var registered int
+	for err := range errs {
+		if err == nil {
+			registered++
+		} else if !errors.Is(err, ErrUserExists) {
+			t.Fatalf("got %v, want %v", err, ErrUserExists)
+		}
+	}
+	if registered != 1 {
+		t.Fatalf("user registered %d times, want once", registered)
+	}
+}
+
+func TestAuthSessionSingleUse(t *testing.T) {
+	store := NewMemoryVerifierStore()
+	v, err := NewVerifier("password", MinIterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetVerifier("user", v)
+	a, err := NewAuthenticator(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewAuthClient("user", "password")
+	login, err := client.Start(Login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, challenge, err := a.Challenge(login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := client.Respond(challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := session.Verify(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Verify(verified); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Verify(proof); !errors.Is(err, ErrAuthNonce) {
+		t.Fatalf("second Verify got %v, want %v", err, ErrAuthNonce)
+	}
+}
+
+func TestAuthSessionFailedProofUsesSession(t *testing.T) {
+	store := NewMemoryVerifierStore()
+	v, err := NewVerifier("password", MinIterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetVerifier("user", v)
+	a, err := NewAuthenticator(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewAuthClient("user", "wrong")
+	login, err := client.Start(Login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, challenge, err := a.Challenge(login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := client.Respond(challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Verify(proof); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("wrong password got %v, want %v", err, ErrAuthFailed)
+	}
+	client.Pass = "password"
+	if proof, err = client.Respond(challenge); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Verify(proof); !errors.Is(err, ErrAuthNonce) {
+		t.Fatalf("retry on the same session got %v, want %v", err, ErrAuthNonce)
+	}
+}
@@ -0,0 +1,189 @@
+package network
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// CaptureMagic is written at the start of every capture file.
+const CaptureMagic = "CHIMERA-CAPTURE"
+
+// CaptureVersion is the version of the capture file format. It is written after CaptureMagic as a single byte.
+const CaptureVersion = 1
+
+// ErrCaptureFormat is returned when reading a file that is not a capture or is of an unsupported version.
+var ErrCaptureFormat = errors.New("not a supported capture file")
+
+// ErrCaptureExists is returned by Recorder when a capture file already exists at its Path.
+var ErrCaptureExists = errors.New("capture file already exists")
+
+// CaptureDirection is the direction a captured Command traveled.
+type CaptureDirection uint8
+
+// Our CaptureDirection values.
+const (
+	Sent CaptureDirection = iota
+	Received
+)
+
+// String returns "sent" or "received".
+func (d CaptureDirection) String() string {
+	if d == Sent {
+		return "sent"
+	}
+	return "received"
+}
+
+// CaptureRecord is a single captured Command.
+type CaptureRecord struct {
+	Time      time.Time
+	Direction CaptureDirection
+	Command   Command
+}
+
+// Recorder writes every Command sent and received on a Connection to a capture file. When the file grows beyond MaxSize, it is rotated to Path.1, Path.1 to Path.2, and so on, with at most MaxFiles rotated files kept. An existing file at Path is never overwritten; recording fails with ErrCaptureExists instead. A Recorder is safe for concurrent use.
+type Recorder struct {
+	Path     string
+	MaxSize  int64 // Maximum size of a capture file in bytes before rotating. Zero disables rotation.
+	MaxFiles int   // Maximum count of rotated files to keep.
+	lock     sync.Mutex
+	file     *os.File
+	writer   *bufio.Writer
+	counter  *countingWriter
+	encoder  *gob.Encoder
+}
+
+// NewRecorder returns a Recorder that writes to the given path, rotating at maxSize bytes and keeping maxFiles rotated files.
+func NewRecorder(path string, maxSize int64, maxFiles int) *Recorder {
+	return &Recorder{
+		Path:     path,
+		MaxSize:  maxSize,
+		MaxFiles: maxFiles,
+	}
+}
+
+// Record writes the given Command to the capture file.
+func (r *Recorder) Record(direction CaptureDirection, cmd Command) (err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		if err = r.open(); err != nil {
+			return
+		}
+	}
+	if err = r.encoder.Encode(&CaptureRecord{
+		Time:      time.Now(),
+		Direction: direction,
+		Command:   cmd,
+	}); err != nil {
+		return
+	}
+	if err = r.writer.Flush(); err != nil {
+		return
+	}
+	if r.MaxSize > 0 && r.counter.Count() >= r.MaxSize {
+		if err = r.close(); err != nil {
+			return
+		}
+		err = r.rotate()
+	}
+	return
+}
+
+// Close closes the current capture file.
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.close()
+}
+
+func (r *Recorder) open() (err error) {
+	r.file, err = os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%w: %s", ErrCaptureExists, r.Path)
+	} else if err != nil {
+		return
+	}
+	r.counter = &countingWriter{w: r.file}
+	r.writer = bufio.NewWriter(r.counter)
+	if _, err = r.writer.WriteString(CaptureMagic); err == nil {
+		err = r.writer.WriteByte(CaptureVersion)
+	}
+	if err == nil {
+		err = r.writer.Flush()
+	}
+	if err != nil {
+		r.file.Close()
+		r.file = nil
+		return
+	}
+	// Each file receives its own encoder so that it carries its own type information.
+	r.encoder = gob.NewEncoder(r.writer)
+	return
+}
+
+func (r *Recorder) close() (err error) {
+	if r.file == nil {
+		return
+	}
+	err = r.writer.Flush()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file = nil
+	r.writer = nil
+	r.counter = nil
+	r.encoder = nil
+	return
+}
+
+func (r *Recorder) rotate() error {
+	if r.MaxFiles <= 0 {
+		return os.Remove(r.Path)
+	}
+	os.Remove(fmt.Sprintf("%s.%d", r.Path, r.MaxFiles))
+	for i := r.MaxFiles - 1; i > 0; i-- {
+		from := fmt.Sprintf("%s.%d", r.Path, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, fmt.Sprintf("%s.%d", r.Path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(r.Path, r.Path+".1")
+}
+
+// CaptureReader reads CaptureRecords from a capture file. RegisterCommands must be called before use.
+type CaptureReader struct {
+	Version uint8
+	decoder *gob.Decoder
+}
+
+// NewCaptureReader reads and validates the capture header from r.
+func NewCaptureReader(r io.Reader) (*CaptureReader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(CaptureMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, ErrCaptureFormat
+	}
+	if string(header[:len(CaptureMagic)]) != CaptureMagic || header[len(CaptureMagic)] > CaptureVersion {
+		return nil, ErrCaptureFormat
+	}
+	return &CaptureReader{
+		Version: header[len(CaptureMagic)],
+		decoder: gob.NewDecoder(br),
+	}, nil
+}
+
+// Next returns the next CaptureRecord. It returns io.EOF at the end of the capture.
+func (c *CaptureReader) Next() (record CaptureRecord, err error) {
+	err = c.decoder.Decode(&record)
+	return
+}
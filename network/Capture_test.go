@@ -0,0 +1,143 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readCapture returns the Commands recorded in the capture file at path.
+func readCapture(t *testing.T, path string) (cmds []Command) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewCaptureReader(f)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		cmds = append(cmds, record.Command)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	RegisterCommands()
+	path := filepath.Join(t.TempDir(), "capture")
+	r := NewRecorder(path, 0, 0)
+	want := []Command{
+		CommandBasic{Type: Okay, String: "sent"},
+		CommandBasic{Type: Nokay, String: "received"},
+	}
+	if err := r.Record(Sent, want[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Record(Received, want[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got := readCapture(t, path)
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRecorderExists(t *testing.T) {
+	RegisterCommands()
+	path := filepath.Join(t.TempDir(), "capture")
+	if err := os.WriteFile(path, []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRecorder(path, 0, 0)
+	if err := r.Record(Sent, CommandBasic{}); !errors.Is(err, ErrCaptureExists) {
+		t.Fatalf("got %v, want %v", err, ErrCaptureExists)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "existing" {
+		t.Fatalf("existing file was overwritten with %q", b)
+	}
+}
+
+func TestRecorderRotation(t *testing.T) {
+	RegisterCommands()
+	path := filepath.Join(t.TempDir(), "capture")
+	// A single large record exceeds MaxSize, so every record ends up in its own file.
+	r := NewRecorder(path, 64, 2)
+	for i := 0; i < 4; i++ {
+		if err := r.Record(Sent, CommandBasic{Type: uint8(i), String: strings.Repeat("x", 64)}); err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("current file still exists after rotation: %v", err)
+	}
+	if _, err := os.Stat(path + ".3"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("more than MaxFiles rotated files kept: %v", err)
+	}
+	// The newest record is in .1, the one before in .2, and the oldest were dropped.
+	for i, want := range []uint8{3, 2} {
+		got := readCapture(t, fmt.Sprintf("%s.%d", path, i+1))
+		if len(got) != 1 || got[0].(CommandBasic).Type != want {
+			t.Fatalf("%s.%d holds %+v, want record %d", path, i+1, got, want)
+		}
+	}
+}
+
+func TestRecorderMaxSize(t *testing.T) {
+	RegisterCommands()
+	path := filepath.Join(t.TempDir(), "capture")
+	r := NewRecorder(path, 1024, 1)
+	var count int
+	for {
+		if err := r.Record(Sent, CommandBasic{String: "record"}); err != nil {
+			t.Fatal(err)
+		}
+		count++
+		if _, err := os.Stat(path + ".1"); err == nil {
+			break
+		} else if count > 1024 {
+			t.Fatal("capture was never rotated")
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() < 1024 {
+		t.Fatalf("rotated at %d bytes, want at least MaxSize", info.Size())
+	}
+	if got := readCapture(t, path+".1"); len(got) != count {
+		t.Fatalf("rotated file holds %d records, want %d", len(got), count)
+	}
+
+	// Recording continues in a new file at Path.
+	if err := r.Record(Sent, CommandBasic{String: "after"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readCapture(t, path); len(got) != 1 || got[0].(CommandBasic).String != "after" {
+		t.Fatalf("new file holds %+v", got)
+	}
+}
@@ -0,0 +1,121 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// DefaultCertificateValidity is how long generated certificates are valid for by default.
+const DefaultCertificateValidity = 10 * 365 * 24 * time.Hour
+
+// GenerateCertificate returns a new self-signed ECDSA P-256 certificate for the given host names and IP addresses, valid for the given duration or DefaultCertificateValidity if zero. Clients cannot verify it against a certificate authority, so they should pin it with KnownHosts. The certificate may also be used as a client certificate for mutual TLS, in which case the first host is used as the client's name.
+func GenerateCertificate(hosts []string, validFor time.Duration) (tls.Certificate, error) {
+	if validFor == 0 {
+		validFor = DefaultCertificateValidity
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Chimera"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	if len(hosts) > 0 {
+		template.Subject.CommonName = hosts[0]
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+// WriteCertificate writes the certificate and its private key as PEM files.
+func WriteCertificate(cert tls.Certificate, certFile, keyFile string) error {
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600); err != nil {
+		return err
+	}
+	var certPEM []byte
+	for _, der := range cert.Certificate {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	return os.WriteFile(certFile, certPEM, 0644)
+}
+
+// LoadOrGenerateCertificate loads the certificate and key from the given PEM files, generating and writing a self-signed certificate for the given hosts if neither exists.
+func LoadOrGenerateCertificate(certFile, keyFile string, hosts []string) (tls.Certificate, error) {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if errors.Is(certErr, os.ErrNotExist) && errors.Is(keyErr, os.ErrNotExist) {
+		cert, err := GenerateCertificate(hosts, 0)
+		if err != nil {
+			return cert, err
+		}
+		return cert, WriteCertificate(cert, certFile, keyFile)
+	}
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+// LoadCertPool returns a pool of the certificates in the given PEM files.
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%s: no certificates found", file)
+		}
+	}
+	return pool, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the DER-encoded certificate as lowercase hex.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
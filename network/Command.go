@@ -11,6 +11,12 @@ type Command interface {
 	GetType() uint32
 }
 
+// TickedCommand is the interface for world-state commands that carry the server tick they were generated on.
+type TickedCommand interface {
+	Command
+	GetTick() uint32
+}
+
 // CommandBasic represent very simple transmissions between the server and
 // the client. This is used for disconnects among other things.
 type CommandBasic struct {
@@ -64,11 +70,17 @@ func (c CommandViewport) GetType() uint32 {
 
 // CommandLogin handles the process of logging in, registering, recovering
 // a password via email, and even deleting the account.
+//
+// Passwords are not sent when using challenge-response: a Login or Delete with a Nonce is answered with a CommandAuth challenge, and a Register carries a Verifier of the password instead. See AuthClient and Authenticator.
 type CommandLogin struct {
-	Type  uint8
-	User  string
-	Pass  string
-	Email string
+	Type     uint8
+	User     string
+	Pass     string // Plaintext password, only used by clients that do not support challenge-response.
+	Email    string
+	Nonce    []byte   // Client nonce that starts a challenge-response Login or Delete.
+	Verifier Verifier // Verifier of the password when using challenge-response to Register or ResetPassword.
+	Token    string   // Token from the recovery email for ResetPassword.
+	Code     string   // Two-factor code confirming a Delete of an account with two-factor authentication.
 }
 
 // GetType returns TYPE_LOGIN
@@ -77,11 +89,46 @@ func (c CommandLogin) GetType() uint32 {
 }
 
 // These are the CommandLogin Types
+//
+//network:constants login
 const (
 	Query = iota
 	Login
 	Register
 	Delete
+	Recover       // Requests a password recovery email for the User or Email.
+	ResetPassword // Sets a new password using the Token from a recovery email.
+)
+
+// CommandAuth carries the challenge-response steps that follow a CommandLogin with a Nonce.
+type CommandAuth struct {
+	Type       uint8
+	Salt       []byte   // Salt of the user's Verifier.
+	Iterations int      // Iterations of the user's Verifier.
+	Nonce      []byte   // The client nonce followed by the server nonce.
+	Proof      []byte   // The client's proof for AuthProof or the server's signature for AuthVerified.
+	Code       string   // One-time password or recovery code for AuthCode, AuthEnrollConfirm, and AuthDisable.
+	URI        string   // otpauth URI of the new secret for AuthEnroll.
+	Codes      []string // Recovery codes for AuthEnrolled.
+}
+
+// GetType returns TypeAuth
+func (c CommandAuth) GetType() uint32 {
+	return TypeAuth
+}
+
+// These are the CommandAuth Types
+//
+//network:constants auth
+const (
+	AuthChallenge     = iota // Server->Client: Salt, Iterations, Nonce
+	AuthProof                // Client->Server: Nonce, Proof
+	AuthVerified             // Server->Client: Proof
+	AuthCode                 // Server->Client: a two-factor code is required after Login. Client->Server: Code
+	AuthEnroll               // Client->Server: requests two-factor enrolment. Server->Client: URI
+	AuthEnrollConfirm        // Client->Server: Code from the new secret
+	AuthEnrolled             // Server->Client: Codes
+	AuthDisable              // Client->Server: Code, disables two-factor authentication
 )
 
 // CommandRejoin signifies the client is rejoining a loaded character.
@@ -118,6 +165,8 @@ func (c CommandCharacter) GetType() uint32 {
 }
 
 // These const values provide the sub-types for CommandCharacter
+//
+//network:constants character
 const (
 	QueryGenera       = iota // Query of Genera, Image(?), Description. Sent when CreateCharacter.
 	QuerySpecies             // Query of Genera+Species, Image, Description, AbilityScores, Skills
@@ -132,6 +181,8 @@ const (
 )
 
 // Our basic return types
+//
+//network:constants basic
 const (
 	Nokay = iota
 	Okay
@@ -163,6 +214,8 @@ func (c CommandAnimation) GetType() uint32 {
 }
 
 // Our Graphics data types.
+//
+//network:constants graphics
 const (
 	GraphicsPng = iota
 )
@@ -199,6 +252,8 @@ type AudioSound struct {
 }
 
 // Our Audio data types.
+//
+//network:constants sound
 const (
 	SoundOgg = iota
 	SoundFlac
@@ -218,6 +273,8 @@ func (c CommandSound) GetType() uint32 {
 }
 
 // Our CommandMap.Type constants.
+//
+//network:constants map
 const (
 	Travel = iota
 )
@@ -233,6 +290,7 @@ type CommandMap struct {
 	Outdoor                               bool
 	OutdoorRed, OutdoorGreen, OutdoorBlue uint8
 	AmbientRed, AmbientGreen, AmbientBlue uint8
+	Tick                                  uint32 // Server tick of the update.
 }
 
 // GetType returns TypeMap
@@ -240,11 +298,17 @@ func (c CommandMap) GetType() uint32 {
 	return TypeMap
 }
 
+// GetTick returns the server tick of the update.
+func (c CommandMap) GetTick() uint32 {
+	return c.Tick
+}
+
 // CommandTiles is a batch update of all tile updates.
 type CommandTiles struct {
 	TileUpdates  []CommandTile
 	LightUpdates []CommandTileLight
 	SkyUpdates   []CommandTileSky
+	Tick         uint32 // Server tick of the update.
 }
 
 // GetType returns TypeTiles
@@ -252,6 +316,11 @@ func (c CommandTiles) GetType() uint32 {
 	return TypeTiles
 }
 
+// GetTick returns the server tick of the update.
+func (c CommandTiles) GetTick() uint32 {
+	return c.Tick
+}
+
 // CommandTile is a list of tiles at a given Tile. This might be expanded to also have a brightness/visibility value.
 type CommandTile struct {
 	X, Y, Z   uint32
@@ -289,6 +358,7 @@ func (c CommandTileSky) GetType() uint32 {
 type CommandObject struct {
 	ObjectID uint32 // id of target object
 	Payload  CommandObjectPayload
+	Tick     uint32 // Server tick of the update. Zero when part of a CommandObjects or CommandSnapshot.
 }
 
 // GetType returns TypeObjectUpdate
@@ -296,6 +366,60 @@ func (c CommandObject) GetType() uint32 {
 	return TypeObjectUpdate
 }
 
+// GetTick returns the server tick of the update.
+func (c CommandObject) GetTick() uint32 {
+	return c.Tick
+}
+
+// CommandObjects is a batch update of many object updates.
+type CommandObjects struct {
+	ObjectUpdates []CommandObject
+	Tick          uint32 // Server tick of the update.
+}
+
+// GetType returns TypeObjectUpdates
+func (c CommandObjects) GetType() uint32 {
+	return TypeObjectUpdates
+}
+
+// GetTick returns the server tick of the update.
+func (c CommandObjects) GetTick() uint32 {
+	return c.Tick
+}
+
+// CommandSnapshot carries the complete visible state of the client's world. It is sent after a CommandRejoin or a map Travel in place of the stream of incremental updates. Any following incremental updates with a Tick at or before the snapshot's Tick are stale.
+type CommandSnapshot struct {
+	Tick                             uint32 // Server tick of the snapshot.
+	Map                              CommandMap
+	Tiles                            CommandTiles
+	Objects                          []CommandObject // Object creation and info payloads.
+	ViewTarget                       uint32
+	ViewHeight, ViewWidth, ViewDepth uint8
+	Statuses                         data.StatusType // All active status effects.
+	Stamina                          time.Duration
+	MaxStamina                       time.Duration
+}
+
+// GetType returns TypeSnapshot
+func (c CommandSnapshot) GetType() uint32 {
+	return TypeSnapshot
+}
+
+// GetTick returns the server tick of the update.
+func (c CommandSnapshot) GetTick() uint32 {
+	return c.Tick
+}
+
+// CommandAck is sent by the client to acknowledge that it has applied all world updates up to and including Tick.
+type CommandAck struct {
+	Tick uint32
+}
+
+// GetType returns TypeAck
+func (c CommandAck) GetType() uint32 {
+	return TypeAck
+}
+
 // CommandObjectPayload is a generic interface for actual payloads.
 type CommandObjectPayload interface {
 }
@@ -350,8 +474,9 @@ func (c CommandInspect) GetType() uint32 {
 
 // CommandCmd is used for player commands to interact with the game world.
 type CommandCmd struct {
-	Cmd  int
-	Data interface{}
+	Cmd   int
+	Data  interface{}
+	Input uint32 // Client input sequence number, zero if unsequenced.
 }
 
 // GetType returns TypeCmd
@@ -360,6 +485,8 @@ func (c CommandCmd) GetType() uint32 {
 }
 
 // Our various CommandCmd.Cmd values
+//
+//network:constants cmd
 const (
 	North = iota
 	South
@@ -378,6 +505,23 @@ const (
 	Wizard
 )
 
+// CommandInputAck is sent by the server to report the last sequenced client input it has processed along with the authoritative position of the client's character afterwards.
+type CommandInputAck struct {
+	Input   uint32 // Last processed CommandCmd or CommandRepeatCmd Input.
+	X, Y, Z uint32 // Authoritative position.
+	Tick    uint32 // Server tick of the update.
+}
+
+// GetType returns TypeInputAck
+func (c CommandInputAck) GetType() uint32 {
+	return TypeInputAck
+}
+
+// GetTick returns the server tick of the update.
+func (c CommandInputAck) GetTick() uint32 {
+	return c.Tick
+}
+
 // CommandClearCmd is used to clear the enter command queue.
 type CommandClearCmd struct{}
 
@@ -402,6 +546,7 @@ type CommandRepeatCmd struct {
 	Cmd    int
 	Cancel bool // If the action should be canceled (used for canceling the repeat)
 	Data   interface{}
+	Input  uint32 // Client input sequence number, zero if unsequenced.
 }
 
 // GetType returns TypeRepeatCmd
@@ -410,6 +555,8 @@ func (c CommandRepeatCmd) GetType() uint32 {
 }
 
 // Our CommandMessage.Type values
+//
+//network:constants message
 const (
 	ServerMessage = iota
 	MapMessage
@@ -437,6 +584,8 @@ func (c CommandMessage) GetType() uint32 {
 }
 
 // Our CommandNoise values
+//
+//network:constants noise
 const (
 	GenericNoise = iota
 	MapNoise
@@ -479,6 +628,7 @@ func (c CommandMusic) GetType() uint32 {
 type CommandStatus struct {
 	Type   data.StatusType // StatusType.
 	Active bool            // If it is (or desired to be) active or not.
+	Tick   uint32          // Server tick of the update. Unused when sent by the client.
 }
 
 // GetType returns TypeStatus
@@ -486,10 +636,16 @@ func (c CommandStatus) GetType() uint32 {
 	return TypeStatus
 }
 
+// GetTick returns the server tick of the update.
+func (c CommandStatus) GetTick() uint32 {
+	return c.Tick
+}
+
 // CommandStamina is used to notify the client of changes in its target's stamina.
 type CommandStamina struct {
 	Stamina    time.Duration
 	MaxStamina time.Duration
+	Tick       uint32 // Server tick of the update.
 }
 
 // GetType returns TypeStamina
@@ -497,6 +653,11 @@ func (c CommandStamina) GetType() uint32 {
 	return TypeStamina
 }
 
+// GetTick returns the server tick of the update.
+func (c CommandStamina) GetTick() uint32 {
+	return c.Tick
+}
+
 // CommandAttack is used to send an attack character action.
 type CommandAttack struct {
 	Direction int    // Direction of the attack. Used with direction melee swings.
@@ -533,6 +694,7 @@ func (c CommandInteract) GetType() uint32 {
 	return TypeInteract
 }
 
+//network:constants interact
 const (
 	InspectInteraction = iota
 	PickupInteraction
@@ -550,13 +712,13 @@ const (
 	TypeLogin
 	TypeRejoin
 	TypeCharacter
-	TypeData
+	TypeData // Reserved, has no Command.
 	TypeTiles
 	TypeTileUpdate
 	TypeTileLight
 	TypeTileSky
 	TypeObjectUpdate
-	TypeInventoryUpdate
+	TypeInventoryUpdate // Reserved, has no Command.
 	TypeInspect
 	TypeStatus
 	TypeMap
@@ -579,4 +741,12 @@ const (
 	TypeSound
 	TypeNoise
 	TypeMusic
+
+	// Types added after the above. New types are appended here so that existing IDs never change.
+	TypeObjectUpdates
+	TypeSnapshot
+	TypeAck
+	TypeInputAck
+	TypeAuth
+	typeCount // typeCount is the number of type IDs. It must remain last.
 )
@@ -3,9 +3,12 @@ package network
 import (
 	"crypto/tls"
 	"encoding/gob"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net"
+	"sync/atomic"
 )
 
 // Connection contains all needed information for network connections between clients and servers.
@@ -16,32 +19,69 @@ type Connection struct {
 	Decoder     *gob.Decoder
 	CmdChan     chan Command  // Becomes valid for reading after ConnectTo(...). See LoopCmd
 	ClosedChan  chan struct{} // Has close(...) called upon it in Close()
+	Recorder    *Recorder     // If set, all sent and received Commands are captured.
+	Stats       *Stats        // Traffic statistics for this connection. Becomes valid after SetConn(...) or ConnectTo(...). If nil, traffic is only counted in GlobalStats.
+	Logger      *slog.Logger  // Logger used for connection events. slog.Default() is used if nil.
+	ID          uint64        // Unique ID of the connection, assigned by SetConn(...) or ConnectTo(...).
+	Identity    *Identity     // Verified client certificate of Connections accepted by a Server using mutual TLS, nil otherwise. See MutualTLSConfig.
+	writeCount  *countingWriter
+	readCount   *countingReader
+	pending     atomic.Int64 // Commands received by LoopCmd that are waiting to be read from the CmdChan.
+	closeReason error
 }
 
+// errClosedByRemote is the close reason of Connections that the remote side closed.
+var errClosedByRemote = errors.New("closed by remote")
+
+// lastConnectionID is the last ID assigned to a Connection.
+var lastConnectionID atomic.Uint64
+
 // SetConn sets the connection's net.Conn to the passed one.
 func (c *Connection) SetConn(conn net.Conn) {
 	if c.IsConnected == true {
 		c.Close()
 	}
+	c.setup(conn)
+}
+
+// setup initializes all basic fields of the Connection for the given net.Conn.
+func (c *Connection) setup(conn net.Conn) {
 	c.Conn = conn
-	c.Encoder = gob.NewEncoder(conn)
-	c.Decoder = gob.NewDecoder(conn)
+	c.writeCount = &countingWriter{w: conn}
+	c.readCount = newCountingReader(conn)
+	c.Encoder = gob.NewEncoder(c.writeCount)
+	c.Decoder = gob.NewDecoder(c.readCount)
 	c.CmdChan = make(chan Command)
 	c.ClosedChan = make(chan struct{})
+	c.Stats = &Stats{queueDepth: c.QueueDepth}
+	c.ID = lastConnectionID.Add(1)
+	c.closeReason = nil
 	c.IsConnected = true
+	trackConnection(c)
+	c.logger().Info("connection established")
+}
+
+// logger returns the Connection's Logger with the connection's ID and remote address as attributes.
+func (c *Connection) logger() *slog.Logger {
+	l := c.Logger
+	if l == nil {
+		l = slog.Default()
+	}
+	remote := ""
+	if c.Conn != nil && c.Conn.RemoteAddr() != nil {
+		remote = c.Conn.RemoteAddr().String()
+	}
+	return l.With(slog.Uint64("conn", c.ID), slog.String("remote", remote))
 }
 
 // ConnectTo connects to the given address, creating/initializing all basic fields of the Connection.
 func (c *Connection) ConnectTo(address string) (err error) {
-	c.Conn, err = net.Dial("tcp", address)
+	conn, err := net.Dial("tcp", address)
 	if err != nil {
+		c.logger().Warn("connect failed", slog.String("address", address), slog.Any("error", err))
 		return
 	}
-	c.Encoder = gob.NewEncoder(c.Conn)
-	c.Decoder = gob.NewDecoder(c.Conn)
-	c.CmdChan = make(chan Command)
-	c.ClosedChan = make(chan struct{})
-	c.IsConnected = true
+	c.setup(conn)
 	// I'm unsure if we should start our Command Loop channel coroutine here as it prevents and use of Send/Receive to the owner of the Connection. However, I suspect it is fine, as we should probably just use the LoopCmd 100% of the time when a client is connected to a server.
 	go c.LoopCmd()
 	return
@@ -49,15 +89,12 @@ func (c *Connection) ConnectTo(address string) (err error) {
 
 // SecureConnectTo functions as per ConnectTo but with an additional tls.Config argument (and target TLS endpoint).
 func (c *Connection) SecureConnectTo(address string, conf *tls.Config) (err error) {
-	c.Conn, err = tls.Dial("tcp", address, conf)
+	conn, err := tls.Dial("tcp", address, conf)
 	if err != nil {
+		c.logger().Warn("connect failed", slog.String("address", address), slog.Any("error", err))
 		return
 	}
-	c.Encoder = gob.NewEncoder(c.Conn)
-	c.Decoder = gob.NewDecoder(c.Conn)
-	c.CmdChan = make(chan Command)
-	c.ClosedChan = make(chan struct{})
-	c.IsConnected = true
+	c.setup(conn)
 	// I'm unsure if we should start our Command Loop channel coroutine here as it prevents and use of Send/Receive to the owner of the Connection. However, I suspect it is fine, as we should probably just use the LoopCmd 100% of the time when a client is connected to a server.
 	go c.LoopCmd()
 	return
@@ -65,13 +102,48 @@ func (c *Connection) SecureConnectTo(address string, conf *tls.Config) (err erro
 
 // Send sends the given Command through the connection.
 func (c *Connection) Send(cmd Command) (err error) {
+	before := c.writeCount.Count()
 	err = c.Encoder.Encode(&cmd)
+	if err != nil {
+		c.stats().recordError(SendError)
+		c.logger().Warn("dropped command", slog.String("command", CommandName(cmd)), slog.Any("error", err))
+		return
+	}
+	c.stats().recordSent(CommandName(cmd), c.writeCount.Count()-before)
+	if c.Recorder != nil {
+		if err := c.Recorder.Record(Sent, cmd); err != nil {
+			c.logger().Warn("capture failed", slog.Any("error", err))
+		}
+	}
 	return
 }
 
+// stats returns the Stats that the Connection's traffic is recorded in.
+func (c *Connection) stats() *Stats {
+	if c.Stats == nil {
+		return &GlobalStats
+	}
+	return c.Stats
+}
+
 // Receive a pending Command from the connection.
 func (c *Connection) Receive(cmd *Command) (err error) {
+	before := c.readCount.Count()
 	err = c.Decoder.Decode(&cmd)
+	if err != nil {
+		// A closed connection is not counted as an error.
+		if !isClosedError(err) {
+			c.stats().recordError(ReceiveError)
+			c.logger().Warn("decode failed", slog.Any("error", err))
+		}
+		return
+	}
+	c.stats().recordReceived(CommandName(*cmd), c.readCount.Count()-before)
+	if c.Recorder != nil {
+		if err := c.Recorder.Record(Received, *cmd); err != nil {
+			c.logger().Warn("capture failed", slog.Any("error", err))
+		}
+	}
 	return
 }
 
@@ -95,30 +167,44 @@ func (c *Connection) ReceiveCommandHandshake() (hs CommandHandshake) {
 	switch t := command.(type) {
 	case CommandHandshake:
 		hs = t
+		c.logger().Info("handshake", slog.Int("version", t.Version), slog.String("program", t.Program))
 	default:
 		panic(fmt.Errorf("expected Net.CommandHandshake(%d), got: %d", TypeBasic, t.GetType()))
 	}
 	return
 }
 
-// Close closes a given connection. This sends a CommandBasic of Cya.
+// Close closes a given connection. This sends a CommandBasic of Cya unless the remote side has already closed it.
 func (c *Connection) Close() {
 	if c.IsConnected == false {
 		return
 	}
 	c.IsConnected = false
+	untrackConnection(c)
 	if r := recover(); r != nil {
-		log.Print("Closing due to problematic connection.")
+		c.logger().Warn("connection closed", slog.String("reason", "problematic connection"), slog.Any("panic", r))
 	} else {
-		c.Send(CommandBasic{
-			Type: Cya,
-		})
+		if c.closeReason != errClosedByRemote {
+			c.Send(CommandBasic{
+				Type: Cya,
+			})
+		}
+		reason := "closed locally"
+		if c.closeReason != nil {
+			reason = c.closeReason.Error()
+		}
+		c.logger().Info("connection closed", slog.String("reason", reason))
 	}
 	c.Conn.Close()
 	var blank struct{}
 	c.ClosedChan <- blank
 }
 
+// QueueDepth returns the count of Commands received by LoopCmd that are waiting to be read from the CmdChan.
+func (c *Connection) QueueDepth() int {
+	return int(c.pending.Load())
+}
+
 // LoopCmd is a loop that receives commands and pumps them into the CmdChan.
 func (c *Connection) LoopCmd() {
 	var cmd Command
@@ -126,9 +212,21 @@ func (c *Connection) LoopCmd() {
 	for c.IsConnected {
 		err = c.Receive(&cmd)
 		if err != nil {
+			if isClosedError(err) {
+				c.closeReason = errClosedByRemote
+			} else {
+				c.closeReason = err
+			}
 			c.Close()
 			break
 		}
+		c.pending.Add(1)
 		c.CmdChan <- cmd
+		c.pending.Add(-1)
 	}
 }
+
+// isClosedError returns whether the error is the result of the connection being closed rather than a failure.
+func isClosedError(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed)
+}
@@ -0,0 +1,59 @@
+package network
+
+import (
+	"bufio"
+	"io"
+	"sync/atomic"
+)
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return
+}
+
+// Count returns the count of bytes written. A nil countingWriter has written nothing.
+func (c *countingWriter) Count() int64 {
+	if c == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&c.n)
+}
+
+// countingReader counts the bytes consumed from r. It implements io.ByteReader so that gob.Decoder does not add its own buffering, which would make the count include bytes that are not yet consumed.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func newCountingReader(r io.Reader) *countingReader {
+	return &countingReader{r: bufio.NewReader(r)}
+}
+
+func (c *countingReader) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return
+}
+
+func (c *countingReader) ReadByte() (b byte, err error) {
+	b, err = c.r.ReadByte()
+	if err == nil {
+		atomic.AddInt64(&c.n, 1)
+	}
+	return
+}
+
+// Count returns the count of bytes consumed. A nil countingReader has consumed nothing.
+func (c *countingReader) Count() int64 {
+	if c == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&c.n)
+}
@@ -0,0 +1,152 @@
+package network
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrFingerprintChanged is returned when a server presents a different certificate than the one pinned for it.
+var ErrFingerprintChanged = errors.New("server certificate fingerprint changed")
+
+// KnownHosts is a client-side trust-on-first-use store of server certificate fingerprints. The first certificate a server presents is pinned and saved to Path, and later connections are only accepted if the server presents the same certificate.
+//
+// The file contains one "address fingerprint" pair per line.
+type KnownHosts struct {
+	Path string
+	// OnChange is called when a server presents a different certificate than the pinned one. The new certificate is pinned and accepted if it returns true. If nil, the connection is refused.
+	OnChange func(address, pinned, presented string) bool
+	Logger   *slog.Logger // Logger is used to warn of changed certificates. slog.Default() is used if nil.
+	lock     sync.Mutex
+	hosts    map[string]string
+}
+
+// LoadKnownHosts loads the KnownHosts from the given path. A missing file is treated as empty and created upon the first pin.
+func LoadKnownHosts(path string) (*KnownHosts, error) {
+	k := &KnownHosts{
+		Path:  path,
+		hosts: make(map[string]string),
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected address and fingerprint", path, line)
+		}
+		k.hosts[fields[0]] = fields[1]
+	}
+	return k, scanner.Err()
+}
+
+// Fingerprint returns the pinned fingerprint of the given address.
+func (k *KnownHosts) Fingerprint(address string) (string, bool) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	fp, ok := k.hosts[address]
+	return fp, ok
+}
+
+// Forget removes the pin of the given address, such as after a server has intentionally changed its certificate.
+func (k *KnownHosts) Forget(address string) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	delete(k.hosts, address)
+	return k.save()
+}
+
+// TLSConfig returns a tls.Config for SecureConnectTo that verifies the server at the given address against its pin rather than against certificate authorities.
+func (k *KnownHosts) TLSConfig(address string) *tls.Config {
+	return &tls.Config{
+		// Certificate authorities are not used, the certificate is checked by VerifyConnection instead.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("server presented no certificate")
+			}
+			return k.Check(address, Fingerprint(cs.PeerCertificates[0].Raw))
+		},
+	}
+}
+
+// Check accepts the fingerprint if it matches the pin of the given address, pinning it if the address is unknown. OnChange is called without the lock held, so it may use the KnownHosts.
+func (k *KnownHosts) Check(address, fingerprint string) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	for {
+		pinned, ok := k.hosts[address]
+		if ok && pinned == fingerprint {
+			return nil
+		}
+		if !ok {
+			break
+		}
+		k.logger().Warn("server certificate changed", slog.String("address", address), slog.String("pinned", pinned), slog.String("presented", fingerprint))
+		accept := false
+		if k.OnChange != nil {
+			k.lock.Unlock()
+			accept = k.OnChange(address, pinned, fingerprint)
+			k.lock.Lock()
+		}
+		if !accept {
+			return fmt.Errorf("%w for %s: pinned %s, presented %s", ErrFingerprintChanged, address, pinned, fingerprint)
+		}
+		// The pin may have changed while OnChange ran, in which case the new pin is checked instead.
+		if current, ok := k.hosts[address]; !ok || current == pinned {
+			break
+		}
+	}
+	if k.hosts == nil {
+		k.hosts = make(map[string]string)
+	}
+	k.hosts[address] = fingerprint
+	return k.save()
+}
+
+func (k *KnownHosts) logger() *slog.Logger {
+	if k.Logger == nil {
+		return slog.Default()
+	}
+	return k.Logger
+}
+
+// save atomically writes the pins to Path. The lock must be held.
+func (k *KnownHosts) save() error {
+	if k.Path == "" {
+		return nil
+	}
+	addresses := make([]string, 0, len(k.hosts))
+	for address := range k.hosts {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	var b strings.Builder
+	for _, address := range addresses {
+		fmt.Fprintf(&b, "%s %s\n", address, k.hosts[address])
+	}
+	if err := os.MkdirAll(filepath.Dir(k.Path), 0700); err != nil {
+		return err
+	}
+	tmp := k.Path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, k.Path)
+}
@@ -0,0 +1,19 @@
+package network
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKnownHostsZeroValue(t *testing.T) {
+	var k KnownHosts
+	if err := k.Check("example.com:1337", "first"); err != nil {
+		t.Fatalf("unknown host refused: %v", err)
+	}
+	if fp, ok := k.Fingerprint("example.com:1337"); !ok || fp != "first" {
+		t.Fatalf("got pin %q, %v, want %q", fp, ok, "first")
+	}
+	if err := k.Check("example.com:1337", "second"); !errors.Is(err, ErrFingerprintChanged) {
+		t.Fatalf("changed fingerprint got %v, want %v", err, ErrFingerprintChanged)
+	}
+}
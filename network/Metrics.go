@@ -0,0 +1,75 @@
+package network
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+)
+
+// PublishExpvar publishes GlobalStats, including QueueDepth, and OpenConnections as an expvar with the given name.
+func PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return struct {
+			StatsSnapshot
+			Connections int
+		}{
+			StatsSnapshot: GlobalStats.Snapshot(),
+			Connections:   OpenConnections(),
+		}
+	}))
+}
+
+// MetricsHandler returns an http.Handler that serves GlobalStats, OpenConnections, and QueueDepth in the Prometheus text exposition format.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w)
+	})
+}
+
+// WriteMetrics writes GlobalStats, OpenConnections, and QueueDepth to w in the Prometheus text exposition format.
+func WriteMetrics(w io.Writer) {
+	s := GlobalStats.Snapshot()
+
+	fmt.Fprintln(w, "# HELP chimera_network_messages_total Commands sent and received by command name.")
+	fmt.Fprintln(w, "# TYPE chimera_network_messages_total counter")
+	writeCommandStats(w, "chimera_network_messages_total", s, func(c CommandStats) uint64 { return c.Messages })
+
+	fmt.Fprintln(w, "# HELP chimera_network_bytes_total Bytes sent and received by command name.")
+	fmt.Fprintln(w, "# TYPE chimera_network_bytes_total counter")
+	writeCommandStats(w, "chimera_network_bytes_total", s, func(c CommandStats) uint64 { return c.Bytes })
+
+	fmt.Fprintln(w, "# HELP chimera_network_errors_total Errors by kind.")
+	fmt.Fprintln(w, "# TYPE chimera_network_errors_total counter")
+	for _, kind := range sortedKeys(s.Errors) {
+		fmt.Fprintf(w, "chimera_network_errors_total{kind=%q} %d\n", kind, s.Errors[kind])
+	}
+
+	fmt.Fprintln(w, "# HELP chimera_network_connections Open connections.")
+	fmt.Fprintln(w, "# TYPE chimera_network_connections gauge")
+	fmt.Fprintf(w, "chimera_network_connections %d\n", OpenConnections())
+
+	fmt.Fprintln(w, "# HELP chimera_network_queue_depth Received commands waiting to be handled.")
+	fmt.Fprintln(w, "# TYPE chimera_network_queue_depth gauge")
+	fmt.Fprintf(w, "chimera_network_queue_depth %d\n", s.QueueDepth)
+}
+
+func writeCommandStats(w io.Writer, metric string, s StatsSnapshot, value func(CommandStats) uint64) {
+	for _, name := range sortedKeys(s.Sent) {
+		fmt.Fprintf(w, "%s{direction=\"sent\",command=%q} %d\n", metric, name, value(s.Sent[name]))
+	}
+	for _, name := range sortedKeys(s.Received) {
+		fmt.Fprintf(w, "%s{direction=\"received\",command=%q} %d\n", metric, name, value(s.Received[name]))
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,40 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// Identity is the verified client certificate of a mutual TLS Connection, for handlers to authorize trusted tooling such as admin consoles, map editors, and inter-server links.
+type Identity struct {
+	CommonName   string
+	Organization []string
+	DNSNames     []string
+	Fingerprint  string // Fingerprint of the client certificate. See Fingerprint.
+	Certificate  *x509.Certificate
+}
+
+// MutualTLSConfig returns a server tls.Config that requires clients to present a certificate signed by one of clientCAs. Self-signed client certificates, such as those from GenerateCertificate, are trusted by adding them to clientCAs directly. Connections accepted by a Server with it have their Identity set.
+func MutualTLSConfig(cert tls.Certificate, clientCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// identityOf returns the Identity of the verified client certificate of the connection, or nil if the client certificate was not verified.
+func identityOf(cs tls.ConnectionState) *Identity {
+	if len(cs.VerifiedChains) == 0 || len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := cs.PeerCertificates[0]
+	return &Identity{
+		CommonName:   cert.Subject.CommonName,
+		Organization: cert.Subject.Organization,
+		DNSNames:     cert.DNSNames,
+		Fingerprint:  Fingerprint(cert.Raw),
+		Certificate:  cert,
+	}
+}
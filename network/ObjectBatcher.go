@@ -0,0 +1,106 @@
+package network
+
+// ObjectBatcher collects the CommandObject updates of a single server tick and coalesces successive updates to the same ObjectID. Call Flush at the end of the tick to acquire the resulting CommandObjects.
+type ObjectBatcher struct {
+	order   []uint32
+	updates map[uint32][]CommandObjectPayload
+}
+
+// Len returns the number of objects that have pending updates. Objects that were created and deleted within the tick have none, as Flush omits them.
+func (b *ObjectBatcher) Len() (n int) {
+	for _, payloads := range b.updates {
+		if len(payloads) > 0 {
+			n++
+		}
+	}
+	return
+}
+
+// Add adds the given CommandObject to the batch, merging it with any previous updates to the same object.
+func (b *ObjectBatcher) Add(cmd CommandObject) {
+	if b.updates == nil {
+		b.updates = make(map[uint32][]CommandObjectPayload)
+	}
+	payloads, exists := b.updates[cmd.ObjectID]
+	if !exists {
+		b.order = append(b.order, cmd.ObjectID)
+	}
+	b.updates[cmd.ObjectID] = coalescePayload(payloads, cmd.Payload)
+}
+
+// Flush returns all pending updates as a CommandObjects and resets the batcher. Objects are ordered by their first update in the tick.
+func (b *ObjectBatcher) Flush() (c CommandObjects) {
+	for _, id := range b.order {
+		for _, payload := range b.updates[id] {
+			c.ObjectUpdates = append(c.ObjectUpdates, CommandObject{
+				ObjectID: id,
+				Payload:  payload,
+			})
+		}
+	}
+	b.order = b.order[:0]
+	b.updates = nil
+	return
+}
+
+// coalescePayload merges a payload into the existing payloads of an object. Only the updates following the last delete are merged, as a delete followed by a create must reach the client in order.
+func coalescePayload(payloads []CommandObjectPayload, payload CommandObjectPayload) []CommandObjectPayload {
+	start := 0
+	for i, p := range payloads {
+		if _, ok := p.(CommandObjectPayloadDelete); ok {
+			start = i + 1
+		}
+	}
+	createIndex := -1
+	for i := start; i < len(payloads); i++ {
+		if _, ok := payloads[i].(CommandObjectPayloadCreate); ok {
+			createIndex = i
+		}
+	}
+
+	switch p := payload.(type) {
+	case CommandObjectPayloadDelete:
+		// An object created within this tick never needs to reach the client.
+		if createIndex >= 0 {
+			return payloads[:createIndex]
+		}
+		if start > 0 {
+			return payloads[:start]
+		}
+		return []CommandObjectPayload{p}
+	case CommandObjectPayloadCreate:
+		return append(payloads[:start], p)
+	case CommandObjectPayloadAnimate:
+		if createIndex >= 0 {
+			create := payloads[createIndex].(CommandObjectPayloadCreate)
+			create.AnimationID = p.AnimationID
+			create.FaceID = p.FaceID
+			payloads[createIndex] = create
+			return payloads
+		}
+	}
+	// Otherwise replace the previous payload of the same type, if any.
+	for i := start; i < len(payloads); i++ {
+		if samePayloadType(payloads[i], payload) {
+			payloads[i] = payload
+			return payloads
+		}
+	}
+	return append(payloads, payload)
+}
+
+// samePayloadType returns whether the two payloads are of the same concrete type.
+func samePayloadType(a, b CommandObjectPayload) bool {
+	switch a.(type) {
+	case CommandObjectPayloadAnimate:
+		_, ok := b.(CommandObjectPayloadAnimate)
+		return ok
+	case CommandObjectPayloadInfo:
+		_, ok := b.(CommandObjectPayloadInfo)
+		return ok
+	case CommandObjectPayloadViewTarget:
+		_, ok := b.(CommandObjectPayloadViewTarget)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chimera-rpg/go-common/data"
+)
+
+func TestObjectBatcherCoalesce(t *testing.T) {
+	create := CommandObjectPayloadCreate{TypeID: 1, AnimationID: 1, FaceID: 1, Height: 1, Width: 1, Depth: 1}
+	animated := create
+	animated.AnimationID, animated.FaceID = 2, 3
+	info := CommandObjectPayloadInfo{Info: []data.ObjectInfo{{Name: "one"}}}
+	info2 := CommandObjectPayloadInfo{Info: []data.ObjectInfo{{Name: "two"}}}
+	tests := []struct {
+		name string
+		add  []CommandObjectPayload
+		want []CommandObjectPayload
+	}{
+		{
+			name: "create and animate",
+			add:  []CommandObjectPayload{create, CommandObjectPayloadAnimate{AnimationID: 2, FaceID: 3}},
+			want: []CommandObjectPayload{animated},
+		},
+		{
+			name: "create and info",
+			add:  []CommandObjectPayload{create, info, info2},
+			want: []CommandObjectPayload{create, info2},
+		},
+		{
+			name: "repeated animates",
+			add:  []CommandObjectPayload{CommandObjectPayloadAnimate{AnimationID: 1}, CommandObjectPayloadAnimate{AnimationID: 2}},
+			want: []CommandObjectPayload{CommandObjectPayloadAnimate{AnimationID: 2}},
+		},
+		{
+			name: "update and delete",
+			add:  []CommandObjectPayload{CommandObjectPayloadAnimate{AnimationID: 2}, info, CommandObjectPayloadDelete{}},
+			want: []CommandObjectPayload{CommandObjectPayloadDelete{}},
+		},
+		{
+			name: "create and delete",
+			add:  []CommandObjectPayload{create, info, CommandObjectPayloadDelete{}},
+			want: nil,
+		},
+		{
+			name: "delete and create",
+			add:  []CommandObjectPayload{CommandObjectPayloadDelete{}, create, CommandObjectPayloadAnimate{AnimationID: 2, FaceID: 3}},
+			want: []CommandObjectPayload{CommandObjectPayloadDelete{}, animated},
+		},
+		{
+			name: "delete, create, and delete",
+			add:  []CommandObjectPayload{CommandObjectPayloadDelete{}, create, CommandObjectPayloadDelete{}},
+			want: []CommandObjectPayload{CommandObjectPayloadDelete{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b ObjectBatcher
+			for _, p := range tt.add {
+				b.Add(CommandObject{ObjectID: 1, Payload: p})
+			}
+			wantLen := 0
+			if len(tt.want) > 0 {
+				wantLen = 1
+			}
+			if got := b.Len(); got != wantLen {
+				t.Errorf("Len is %d, want %d", got, wantLen)
+			}
+			var got []CommandObjectPayload
+			for _, cmd := range b.Flush().ObjectUpdates {
+				if cmd.ObjectID != 1 {
+					t.Errorf("update for object %d", cmd.ObjectID)
+				}
+				got = append(got, cmd.Payload)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flushed %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObjectBatcherFlushOrder(t *testing.T) {
+	var b ObjectBatcher
+	b.Add(CommandObject{ObjectID: 3, Payload: CommandObjectPayloadAnimate{AnimationID: 1}})
+	b.Add(CommandObject{ObjectID: 1, Payload: CommandObjectPayloadDelete{}})
+	b.Add(CommandObject{ObjectID: 2, Payload: CommandObjectPayloadCreate{}})
+	b.Add(CommandObject{ObjectID: 4, Payload: CommandObjectPayloadCreate{}})
+	b.Add(CommandObject{ObjectID: 1, Payload: CommandObjectPayloadCreate{}})
+	b.Add(CommandObject{ObjectID: 3, Payload: CommandObjectPayloadAnimate{AnimationID: 2}})
+	b.Add(CommandObject{ObjectID: 4, Payload: CommandObjectPayloadDelete{}})
+	if got := b.Len(); got != 3 {
+		t.Fatalf("Len is %d, want 3", got)
+	}
+	want := []CommandObject{
+		{ObjectID: 3, Payload: CommandObjectPayloadAnimate{AnimationID: 2}},
+		{ObjectID: 1, Payload: CommandObjectPayloadDelete{}},
+		{ObjectID: 1, Payload: CommandObjectPayloadCreate{}},
+		{ObjectID: 2, Payload: CommandObjectPayloadCreate{}},
+	}
+	if got := b.Flush().ObjectUpdates; !reflect.DeepEqual(got, want) {
+		t.Fatalf("flushed %+v, want %+v", got, want)
+	}
+	if got := b.Len(); got != 0 {
+		t.Fatalf("Len is %d after Flush, want 0", got)
+	}
+	if got := b.Flush().ObjectUpdates; len(got) != 0 {
+		t.Fatalf("second Flush returned %+v", got)
+	}
+}
@@ -2,43 +2,187 @@ package network
 
 import (
 	"encoding/gob"
+	"fmt"
+	"reflect"
+	"slices"
 )
 
-// RegisterCommands registers our various Command structures with their gob names.
+type registeredCommand struct {
+	id        uint32 // Type constant returned by the Command's GetType. Unused for object payloads.
+	name      string // Name the value is registered with gob as.
+	value     interface{}
+	constants map[string][]Constant // Named values of the value's fields.
+}
+
+// registeredCommands is our list of Command structures with their type IDs and gob names. This is the single source of truth for the protocol, and it is checked against GetType at init.
+var registeredCommands = []registeredCommand{
+	{TypeHandshake, "H", CommandHandshake{}, nil},
+	{TypeFeatures, "F", CommandFeatures{}, nil},
+	{TypeBasic, "B", CommandBasic{}, map[string][]Constant{"Type": basicConstants}},
+	{TypeMap, "M", CommandMap{}, map[string][]Constant{"Type": mapConstants}},
+	{TypeLogin, "L", CommandLogin{}, map[string][]Constant{"Type": loginConstants}},
+	{TypeAuth, "Au", CommandAuth{}, map[string][]Constant{"Type": authConstants}},
+	{TypeRejoin, "R", CommandRejoin{}, nil},
+	{TypeCharacter, "C", CommandCharacter{}, map[string][]Constant{"Type": characterConstants}},
+	{TypeAnimation, "A", CommandAnimation{}, map[string][]Constant{"Type": basicConstants}},
+	{TypeGraphics, "G", CommandGraphics{}, map[string][]Constant{"Type": basicConstants, "DataType": graphicsConstants}},
+	{TypeTileUpdate, "T", CommandTile{}, nil},
+	{TypeTiles, "Tt", CommandTiles{}, nil},
+	{TypeTileLight, "Tl", CommandTileLight{}, nil},
+	{TypeTileSky, "Ts", CommandTileSky{}, nil},
+	{TypeObjectUpdate, "O", CommandObject{}, nil},
+	{TypeObjectUpdates, "Oo", CommandObjects{}, nil},
+	{TypeSnapshot, "Sn", CommandSnapshot{}, map[string][]Constant{"Statuses": statusConstants}},
+	{TypeAck, "Ak", CommandAck{}, nil},
+	{TypeCmd, "c", CommandCmd{}, map[string][]Constant{"Cmd": cmdConstants}},
+	{TypeClearCmd, "cl", CommandClearCmd{}, nil},
+	{TypeExtCmd, "e", CommandExtCmd{}, nil},
+	{TypeRepeatCmd, "r", CommandRepeatCmd{}, map[string][]Constant{"Cmd": cmdConstants}},
+	{TypeInputAck, "ia", CommandInputAck{}, nil},
+	{TypeMessage, "m", CommandMessage{}, map[string][]Constant{"Type": messageConstants}},
+	{TypeStatus, "s", CommandStatus{}, map[string][]Constant{"Type": statusConstants}},
+	{TypeStamina, "t", CommandStamina{}, nil},
+	{TypeInspect, "I", CommandInspect{}, nil},
+	{TypeViewport, "Vp", CommandViewport{}, nil},
+	{TypeSound, "S", CommandSound{}, map[string][]Constant{"Type": basicConstants, "DataType": soundConstants}},
+	{TypeAudio, "a", CommandAudio{}, map[string][]Constant{"Type": basicConstants}},
+	{TypeNoise, "n", CommandNoise{}, map[string][]Constant{"Type": noiseConstants}},
+	{TypeMusic, "Mu", CommandMusic{}, nil},
+	{TypeAttack, "At", CommandAttack{}, map[string][]Constant{"Direction": cmdConstants}},
+	{TypeDamage, "D", CommandDamage{}, map[string][]Constant{"Type": attackTypeConstants}},
+	{TypeInteract, "In", CommandInteract{}, map[string][]Constant{"Type": interactConstants}},
+}
+
+// registeredPayloads is our list of object payloads with their gob names.
+var registeredPayloads = []registeredCommand{
+	{0, "Oc", CommandObjectPayloadCreate{}, nil},
+	{0, "Od", CommandObjectPayloadDelete{}, nil},
+	{0, "Oa", CommandObjectPayloadAnimate{}, nil},
+	{0, "Ov", CommandObjectPayloadViewTarget{}, nil},
+	{0, "Oi", CommandObjectPayloadInfo{}, nil},
+}
+
+// reservedTypes are type IDs that are reserved but have no Command.
+var reservedTypes = []uint32{
+	TypeData,
+	TypeInventoryUpdate,
+}
+
+// registered is registeredCommands followed by registeredPayloads.
+var registered = append(append([]registeredCommand{}, registeredCommands...), registeredPayloads...)
+
+var (
+	registeredByID   = make(map[uint32]*registeredCommand)
+	registeredByName = make(map[string]*registeredCommand)
+	registeredByType = make(map[reflect.Type]*registeredCommand)
+)
+
+func init() {
+	if err := buildRegistry(); err != nil {
+		panic(err)
+	}
+}
+
+// buildRegistry fills our lookup maps, ensuring that no type ID, gob name, or Go type is registered twice, that each Command's GetType agrees with its registered ID, and that every type ID is either registered or reserved.
+func buildRegistry() error {
+	for i := range registered {
+		r := &registered[i]
+		t := reflect.TypeOf(r.value)
+		if other, ok := registeredByName[r.name]; ok {
+			return fmt.Errorf("network: gob name %q registered for both %T and %T", r.name, other.value, r.value)
+		}
+		if other, ok := registeredByType[t]; ok {
+			return fmt.Errorf("network: %T registered as both %q and %q", r.value, other.name, r.name)
+		}
+		registeredByName[r.name] = r
+		registeredByType[t] = r
+		if i >= len(registeredCommands) {
+			continue
+		}
+		c, ok := r.value.(Command)
+		if !ok {
+			return fmt.Errorf("network: %T is registered as a Command but is not one", r.value)
+		}
+		if c.GetType() != r.id {
+			return fmt.Errorf("network: %T is registered as type %d but GetType returns %d", r.value, r.id, c.GetType())
+		}
+		if other, ok := registeredByID[r.id]; ok {
+			return fmt.Errorf("network: type %d registered for both %T and %T", r.id, other.value, r.value)
+		}
+		registeredByID[r.id] = r
+	}
+	for _, id := range reservedTypes {
+		if r, ok := registeredByID[id]; ok {
+			return fmt.Errorf("network: reserved type %d registered for %T", id, r.value)
+		}
+	}
+	for id := uint32(0); id < typeCount; id++ {
+		if _, ok := registeredByID[id]; !ok && !slices.Contains(reservedTypes, id) {
+			return fmt.Errorf("network: type %d is neither registered nor reserved", id)
+		}
+	}
+	for id, r := range registeredByID {
+		if id >= typeCount {
+			return fmt.Errorf("network: %T is registered as type %d, which is not a Type constant", r.value, id)
+		}
+	}
+	return nil
+}
+
+// RegisterCommands registers our various Command structures and object payloads with their gob names.
 func RegisterCommands() {
-	gob.RegisterName("H", CommandHandshake{})
-	gob.RegisterName("F", CommandFeatures{})
-	gob.RegisterName("B", CommandBasic{})
-	gob.RegisterName("M", CommandMap{})
-	gob.RegisterName("L", CommandLogin{})
-	gob.RegisterName("R", CommandRejoin{})
-	gob.RegisterName("C", CommandCharacter{})
-	gob.RegisterName("A", CommandAnimation{})
-	gob.RegisterName("G", CommandGraphics{})
-	gob.RegisterName("T", CommandTile{})
-	gob.RegisterName("Tt", CommandTiles{})
-	gob.RegisterName("Tl", CommandTileLight{})
-	gob.RegisterName("Ts", CommandTileSky{})
-	gob.RegisterName("O", CommandObject{})
-	gob.RegisterName("Oc", CommandObjectPayloadCreate{})
-	gob.RegisterName("Od", CommandObjectPayloadDelete{})
-	gob.RegisterName("Oa", CommandObjectPayloadAnimate{})
-	gob.RegisterName("Ov", CommandObjectPayloadViewTarget{})
-	gob.RegisterName("Oi", CommandObjectPayloadInfo{})
-	gob.RegisterName("c", CommandCmd{})
-	gob.RegisterName("cl", CommandClearCmd{})
-	gob.RegisterName("e", CommandExtCmd{})
-	gob.RegisterName("r", CommandRepeatCmd{})
-	gob.RegisterName("m", CommandMessage{})
-	gob.RegisterName("s", CommandStatus{})
-	gob.RegisterName("t", CommandStamina{})
-	gob.RegisterName("I", CommandInspect{})
-	gob.RegisterName("Vp", CommandViewport{})
-	gob.RegisterName("S", CommandSound{})
-	gob.RegisterName("a", CommandAudio{})
-	gob.RegisterName("n", CommandNoise{})
-	gob.RegisterName("Mu", CommandMusic{})
-	gob.RegisterName("At", CommandAttack{})
-	gob.RegisterName("D", CommandDamage{})
-	gob.RegisterName("In", CommandInteract{})
+	for _, r := range registered {
+		gob.RegisterName(r.name, r.value)
+	}
+}
+
+// CommandName returns the gob name that the given Command or object payload is registered with, or an empty string if it is not registered.
+func CommandName(v interface{}) string {
+	if r, ok := registeredByType[reflect.TypeOf(v)]; ok {
+		return r.name
+	}
+	return ""
+}
+
+// CommandNames returns the gob names of all registered Commands and object payloads in registration order.
+func CommandNames() []string {
+	names := make([]string, len(registered))
+	for i, r := range registered {
+		names[i] = r.name
+	}
+	return names
+}
+
+// CommandValue returns the zero value of the Command or object payload registered with the given gob name, or nil if there is none.
+func CommandValue(name string) interface{} {
+	if r, ok := registeredByName[name]; ok {
+		return r.value
+	}
+	return nil
+}
+
+// CommandType returns the type ID of the Command registered with the given gob name.
+func CommandType(name string) (uint32, bool) {
+	if r, ok := registeredByName[name]; ok {
+		if _, isCommand := r.value.(Command); isCommand {
+			return r.id, true
+		}
+	}
+	return 0, false
+}
+
+// CommandTypeName returns the gob name of the Command registered with the given type ID.
+func CommandTypeName(id uint32) (string, bool) {
+	if r, ok := registeredByID[id]; ok {
+		return r.name, true
+	}
+	return "", false
+}
+
+// NewCommand returns the zero value of the Command registered with the given type ID, or nil if there is none.
+func NewCommand(id uint32) Command {
+	if r, ok := registeredByID[id]; ok {
+		return r.value.(Command)
+	}
+	return nil
 }
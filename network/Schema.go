@@ -0,0 +1,216 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/chimera-rpg/go-common/data"
+)
+
+// Constant is a named value of a Command field, such as Okay for CommandBasic.Type.
+type Constant struct {
+	Name  string
+	Value int64
+}
+
+// FieldSchema describes a single field of a Command, object payload, or a type used by one.
+type FieldSchema struct {
+	Name      string
+	Type      string     // Go type of the field.
+	Constants []Constant `json:",omitempty"` // Named values of the field.
+}
+
+// TypeSchema describes a struct type of the protocol.
+type TypeSchema struct {
+	Name     string // Go type name.
+	WireName string `json:",omitempty"` // Name the type is registered with gob as, if any.
+	TypeID   uint32 // GetType value of Commands. Unused for object payloads and other types.
+	Payload  bool   `json:",omitempty"` // Payload is set for object payloads, which are sent within CommandObject.
+	Fields   []FieldSchema
+}
+
+// ProtocolSchema is a machine-readable description of the protocol.
+type ProtocolSchema struct {
+	Version  int
+	Commands []TypeSchema // Registered Commands and object payloads in registration order.
+	Types    []TypeSchema // Other struct types used by Commands, sorted by name.
+}
+
+// Schema returns the description of the current protocol.
+func Schema() ProtocolSchema {
+	s := ProtocolSchema{
+		Version: Version,
+	}
+	seen := make(map[reflect.Type]bool)
+	var others []reflect.Type
+	for _, r := range registered {
+		t := reflect.TypeOf(r.value)
+		seen[t] = true
+		ts := typeSchema(t, r.constants, &others)
+		ts.WireName = r.name
+		if c, ok := r.value.(Command); ok {
+			ts.TypeID = c.GetType()
+		} else {
+			ts.Payload = true
+		}
+		s.Commands = append(s.Commands, ts)
+	}
+	for len(others) > 0 {
+		t := others[0]
+		others = others[1:]
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		s.Types = append(s.Types, typeSchema(t, nil, &others))
+	}
+	sort.Slice(s.Types, func(i, j int) bool {
+		return s.Types[i].Name < s.Types[j].Name
+	})
+	return s
+}
+
+// typeSchema describes the struct type t and appends any struct types used by its fields to others.
+func typeSchema(t reflect.Type, constants map[string][]Constant, others *[]reflect.Type) TypeSchema {
+	ts := TypeSchema{
+		Name:   t.String(),
+		Fields: []FieldSchema{},
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		ts.Fields = append(ts.Fields, FieldSchema{
+			Name:      f.Name,
+			Type:      typeName(f.Type),
+			Constants: constants[f.Name],
+		})
+		*others = append(*others, structTypes(f.Type)...)
+	}
+	return ts
+}
+
+// structTypes returns the struct types contained within t, ignoring those that encode themselves.
+func structTypes(t reflect.Type) []reflect.Type {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Pointer:
+		return structTypes(t.Elem())
+	case reflect.Map:
+		return append(structTypes(t.Key()), structTypes(t.Elem())...)
+	case reflect.Struct:
+		if t.PkgPath() == "time" {
+			return nil
+		}
+		if t.Name() == "" {
+			var types []reflect.Type
+			for i := 0; i < t.NumField(); i++ {
+				types = append(types, structTypes(t.Field(i).Type)...)
+			}
+			return types
+		}
+		return []reflect.Type{t}
+	}
+	return nil
+}
+
+// typeName returns the Go name of t, describing anonymous structs without their tags.
+func typeName(t reflect.Type) string {
+	if t.Name() != "" {
+		return t.String()
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		return "[]" + typeName(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), typeName(t.Elem()))
+	case reflect.Pointer:
+		return "*" + typeName(t.Elem())
+	case reflect.Map:
+		return "map[" + typeName(t.Key()) + "]" + typeName(t.Elem())
+	case reflect.Struct:
+		fields := make([]string, 0, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			fields = append(fields, t.Field(i).Name+" "+typeName(t.Field(i).Type))
+		}
+		return "struct { " + strings.Join(fields, "; ") + " }"
+	}
+	return t.String()
+}
+
+// WriteJSON writes the schema as indented JSON.
+func (s ProtocolSchema) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// WriteMarkdown writes the schema as a Markdown document.
+func (s ProtocolSchema) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Chimera Protocol\n\nProtocol version %d. Commands are gob encoded as interface values using their wire names.\n", s.Version)
+
+	b.WriteString("\n## Commands\n")
+	for _, ts := range s.Commands {
+		if !ts.Payload {
+			fmt.Fprintf(&b, "\n### %s (`%s`)\n\nType ID %d.\n", ts.Name, ts.WireName, ts.TypeID)
+			writeFieldsMarkdown(&b, ts.Fields)
+		}
+	}
+	b.WriteString("\n## Object Payloads\n\nObject payloads are sent as the Payload of a CommandObject.\n")
+	for _, ts := range s.Commands {
+		if ts.Payload {
+			fmt.Fprintf(&b, "\n### %s (`%s`)\n", ts.Name, ts.WireName)
+			writeFieldsMarkdown(&b, ts.Fields)
+		}
+	}
+	b.WriteString("\n## Types\n")
+	for _, ts := range s.Types {
+		fmt.Fprintf(&b, "\n### %s\n", ts.Name)
+		writeFieldsMarkdown(&b, ts.Fields)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFieldsMarkdown(b *strings.Builder, fields []FieldSchema) {
+	if len(fields) == 0 {
+		b.WriteString("\nNo fields.\n")
+		return
+	}
+	b.WriteString("\n| Field | Type | Values |\n| --- | --- | --- |\n")
+	for _, f := range fields {
+		values := make([]string, len(f.Constants))
+		for i, c := range f.Constants {
+			values[i] = fmt.Sprintf("%s=%d", c.Name, c.Value)
+		}
+		fmt.Fprintf(b, "| %s | `%s` | %s |\n", f.Name, f.Type, strings.Join(values, ", "))
+	}
+}
+
+//go:generate go run ./internal/constgen -o Constants.go Command.go
+
+// Our named values of Command fields that are declared in the data package. The others are generated in Constants.go.
+var (
+	statusConstants     = mapConstantsOf(data.StatusMapToString)
+	attackTypeConstants = mapConstantsOf(data.AttackTypeToStringMap)
+)
+
+// mapConstantsOf returns Constants for a data package value to string map, sorted by value.
+func mapConstantsOf[T ~uint16 | ~uint32](m map[T]string) []Constant {
+	constants := make([]Constant, 0, len(m))
+	for v, name := range m {
+		constants = append(constants, Constant{name, int64(v)})
+	}
+	sort.Slice(constants, func(i, j int) bool {
+		return constants[i].Value < constants[j].Value
+	})
+	return constants
+}
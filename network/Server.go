@@ -0,0 +1,161 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// Server accepts Connections and passes each to its Handler in a new goroutine. TLS handshakes are completed before the Handler is called, so that the Connection's Identity is set when using mutual TLS.
+type Server struct {
+	Handler          func(c *Connection) // Handler is called for each accepted Connection.
+	Logger           *slog.Logger        // Logger is used for server events and passed to each Connection. slog.Default() is used if nil.
+	HandshakeTimeout time.Duration       // HandshakeTimeout limits TLS handshakes. Defaults to DefaultHandshakeTimeout.
+	Admission        Admission           // Admission, if set, may refuse connections as soon as they are accepted. See Admitter.
+	lock             sync.Mutex
+	listeners        []net.Listener
+	closed           bool
+}
+
+// DefaultHandshakeTimeout is the default Server HandshakeTimeout.
+const DefaultHandshakeTimeout = 10 * time.Second
+
+// ErrServerClosed is returned by the Server's Listen and Serve methods after Close.
+var ErrServerClosed = errors.New("server closed")
+
+// ErrNoHandler is returned by the Server's Listen and Serve methods if it has no Handler.
+var ErrNoHandler = errors.New("server has no handler")
+
+// The delays between retries of temporary accept errors, such as running out of file descriptors.
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+)
+
+// Listen listens on the given TCP address and serves Connections until Close is called.
+func (s *Server) Listen(address string) error {
+	if s.Handler == nil {
+		return ErrNoHandler
+	}
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// ListenTLS functions as per Listen but with an additional tls.Config argument. Use MutualTLSConfig to require client certificates.
+func (s *Server) ListenTLS(address string, conf *tls.Config) error {
+	if s.Handler == nil {
+		return ErrNoHandler
+	}
+	l, err := tls.Listen("tcp", address, conf)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts Connections on the given listener until Close is called. Temporary accept errors are retried with increasing delays.
+func (s *Server) Serve(l net.Listener) error {
+	if s.Handler == nil {
+		l.Close()
+		return ErrNoHandler
+	}
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners = append(s.listeners, l)
+	s.lock.Unlock()
+
+	s.logger().Info("listening", slog.String("address", l.Addr().String()))
+	var delay time.Duration
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.lock.Lock()
+			closed := s.closed
+			s.lock.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				delay = min(max(delay*2, minAcceptDelay), maxAcceptDelay)
+				s.logger().Warn("accept failed, retrying", slog.Any("error", err), slog.Duration("delay", delay))
+				time.Sleep(delay)
+				continue
+			}
+			s.logger().Error("accept failed", slog.Any("error", err))
+			return err
+		}
+		delay = 0
+		if s.Admission != nil {
+			if err := s.Admission.Admit(conn.RemoteAddr()); err != nil {
+				s.logger().Warn("connection refused", slog.String("remote", conn.RemoteAddr().String()), slog.Any("error", err))
+				conn.Close()
+				continue
+			}
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn completes the TLS handshake of TLS connections and then passes the Connection to the Handler.
+func (s *Server) serveConn(conn net.Conn) {
+	if s.Admission != nil {
+		defer s.Admission.Release(conn.RemoteAddr())
+	}
+	var identity *Identity
+	if tc, ok := conn.(*tls.Conn); ok {
+		timeout := s.HandshakeTimeout
+		if timeout == 0 {
+			timeout = DefaultHandshakeTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := tc.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			s.logger().Warn("handshake failed", slog.String("remote", conn.RemoteAddr().String()), slog.Any("error", err))
+			conn.Close()
+			return
+		}
+		identity = identityOf(tc.ConnectionState())
+	}
+	c := &Connection{
+		Logger:   s.Logger,
+		Identity: identity,
+	}
+	c.SetConn(conn)
+	if identity != nil {
+		c.logger().Info("client identified", slog.String("name", identity.CommonName), slog.String("fingerprint", identity.Fingerprint))
+	}
+	s.Handler(c)
+}
+
+// Close stops all listeners. Already accepted Connections are unaffected.
+func (s *Server) Close() (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	for _, l := range s.listeners {
+		if lerr := l.Close(); err == nil {
+			err = lerr
+		}
+	}
+	s.listeners = nil
+	return
+}
+
+func (s *Server) logger() *slog.Logger {
+	if s.Logger == nil {
+		return slog.Default()
+	}
+	return s.Logger
+}
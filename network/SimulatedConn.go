@@ -0,0 +1,360 @@
+package network
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrSimulatedDisconnect is returned by a SimulatedConn after Disconnect has been called.
+var ErrSimulatedDisconnect = errors.New("simulated disconnect")
+
+// Conditions are the network conditions applied by a SimulatedConn. They apply separately to each direction.
+type Conditions struct {
+	Latency   time.Duration // Latency is added to all data.
+	Jitter    time.Duration // Jitter is the maximum random variance added to or removed from Latency.
+	Bandwidth int           // Bandwidth is the maximum bytes per second. Zero is unlimited.
+}
+
+// SimulatedConn wraps a net.Conn to inject latency, jitter, bandwidth limits, and disconnects. It is intended for testing and can be passed to Connection.SetConn. Conditions may be changed at any time with SetConditions. Close delivers any data in flight before closing, whereas Disconnect discards it. Read deadlines apply to the delayed data returned by Read. Write deadlines are passed to the wrapped net.Conn, where a timeout fails the connection.
+type SimulatedConn struct {
+	net.Conn
+	lock            sync.Mutex
+	writeLock       sync.Mutex     // writeLock keeps concurrent Writes in order while they wait for queue space.
+	writers         sync.WaitGroup // writers counts the Writes that may still queue data.
+	conditions      Conditions
+	in, out         simulatedPipe
+	writes          chan simulatedPacket
+	reads           chan simulatedPacket
+	readBuffer      []byte
+	readPending     *simulatedPacket // readPending is received but not yet due.
+	readDeadline    time.Time
+	deadlineChanged chan struct{} // deadlineChanged is closed and replaced whenever the read deadline changes.
+	err             error
+	closing         chan struct{} // closing is closed by Close. Data queued until then is still delivered.
+	closed          chan struct{}
+	closedOnce      sync.Once
+}
+
+type simulatedPacket struct {
+	data []byte
+	due  time.Time
+	err  error
+}
+
+// simulatedPipe schedules the delivery of data in a single direction.
+type simulatedPipe struct {
+	free    time.Time // When the bandwidth is next available.
+	lastDue time.Time // Data is never delivered before earlier data.
+}
+
+// NewSimulatedConn returns a SimulatedConn wrapping conn with the given conditions.
+func NewSimulatedConn(conn net.Conn, conditions Conditions) *SimulatedConn {
+	s := &SimulatedConn{
+		Conn:            conn,
+		conditions:      conditions,
+		writes:          make(chan simulatedPacket, 1024),
+		reads:           make(chan simulatedPacket, 1024),
+		deadlineChanged: make(chan struct{}),
+		closing:         make(chan struct{}),
+		closed:          make(chan struct{}),
+	}
+	go s.loopWrite()
+	go s.loopRead()
+	return s
+}
+
+// SetConditions changes the conditions. Data already in flight is unaffected.
+func (s *SimulatedConn) SetConditions(conditions Conditions) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.conditions = conditions
+}
+
+// Conditions returns the current conditions.
+func (s *SimulatedConn) Conditions() Conditions {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.conditions
+}
+
+// Disconnect abruptly closes the wrapped connection, discarding any data in flight.
+func (s *SimulatedConn) Disconnect() {
+	s.lock.Lock()
+	if s.err == nil {
+		s.err = ErrSimulatedDisconnect
+	}
+	s.lock.Unlock()
+	s.shutdown()
+}
+
+// DisconnectAfter calls Disconnect after the given duration.
+func (s *SimulatedConn) DisconnectAfter(d time.Duration) {
+	time.AfterFunc(d, s.Disconnect)
+}
+
+// Write queues the data for delayed delivery to the wrapped connection. It blocks while the queue is full.
+func (s *SimulatedConn) Write(b []byte) (int, error) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	s.lock.Lock()
+	if s.err != nil {
+		s.lock.Unlock()
+		return 0, s.err
+	}
+	if s.isClosing() {
+		s.lock.Unlock()
+		return 0, net.ErrClosed
+	}
+	s.writers.Add(1)
+	defer s.writers.Done()
+	due := s.out.schedule(s.conditions, len(b))
+	s.lock.Unlock()
+
+	data := make([]byte, len(b))
+	copy(data, b)
+	select {
+	case s.writes <- simulatedPacket{data: data, due: due}:
+		return len(b), nil
+	case <-s.closing:
+		return 0, net.ErrClosed
+	case <-s.closed:
+		return 0, s.closeErr()
+	}
+}
+
+// Read returns data from the wrapped connection once it is due.
+func (s *SimulatedConn) Read(b []byte) (int, error) {
+	for len(s.readBuffer) == 0 {
+		if p := s.readPending; p != nil && !time.Now().Before(p.due) {
+			s.readPending = nil
+			if p.err != nil {
+				return 0, p.err
+			}
+			s.readBuffer = p.data
+			break
+		}
+		s.lock.Lock()
+		deadline, changed := s.readDeadline, s.deadlineChanged
+		s.lock.Unlock()
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		// Wake for the deadline or for the pending data becoming due, whichever is first.
+		wake := deadline
+		var reads <-chan simulatedPacket
+		if s.readPending == nil {
+			reads = s.reads
+		} else if wake.IsZero() || s.readPending.due.Before(wake) {
+			wake = s.readPending.due
+		}
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !wake.IsZero() {
+			timer = time.NewTimer(time.Until(wake))
+			timeout = timer.C
+		}
+		select {
+		case p := <-reads:
+			s.readPending = &p
+		case <-timeout:
+		case <-changed:
+		case <-s.closed:
+			return 0, s.closeErr()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	n := copy(b, s.readBuffer)
+	s.readBuffer = s.readBuffer[n:]
+	return n, nil
+}
+
+// SetDeadline sets the read deadline of the SimulatedConn and the write deadline of the wrapped connection.
+func (s *SimulatedConn) SetDeadline(t time.Time) error {
+	s.SetReadDeadline(t)
+	return s.Conn.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the deadline for Read, including any Read that is already blocked.
+func (s *SimulatedConn) SetReadDeadline(t time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.readDeadline = t
+	close(s.deadlineChanged)
+	s.deadlineChanged = make(chan struct{})
+	return nil
+}
+
+// Close closes the wrapped connection once all data in flight has been written.
+func (s *SimulatedConn) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.isClosing() {
+		return net.ErrClosed
+	}
+	// A Write blocked on a full queue gives up rather than holding up Close.
+	close(s.closing)
+	return nil
+}
+
+// isClosing returns whether Close has been called. The caller must hold lock.
+func (s *SimulatedConn) isClosing() bool {
+	select {
+	case <-s.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// shutdown immediately closes the wrapped connection.
+func (s *SimulatedConn) shutdown() {
+	s.closedOnce.Do(func() {
+		close(s.closed)
+		s.Conn.Close()
+	})
+}
+
+func (s *SimulatedConn) closeErr() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	return net.ErrClosed
+}
+
+// wait waits until the given time, returning false if the connection is closed first.
+func (s *SimulatedConn) wait(due time.Time) bool {
+	d := time.Until(due)
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-s.closed:
+		return false
+	}
+}
+
+func (s *SimulatedConn) loopWrite() {
+	for {
+		select {
+		case p := <-s.writes:
+			if !s.deliver(p) {
+				return
+			}
+		case <-s.closing:
+			s.flush()
+			return
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// flush delivers the queued data once Close has been called and then shuts down.
+func (s *SimulatedConn) flush() {
+	// Writes that were underway when Close was called may still queue data.
+	writersDone := make(chan struct{})
+	go func() {
+		s.writers.Wait()
+		close(writersDone)
+	}()
+	for {
+		select {
+		case p := <-s.writes:
+			if !s.deliver(p) {
+				return
+			}
+		case <-writersDone:
+			// No more data can be queued, so deliver what remains.
+			for len(s.writes) > 0 {
+				if !s.deliver(<-s.writes) {
+					return
+				}
+			}
+			s.shutdown()
+			return
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// deliver writes the packet to the wrapped connection once it is due, returning false if the connection is closed.
+func (s *SimulatedConn) deliver(p simulatedPacket) bool {
+	if !s.wait(p.due) {
+		return false
+	}
+	if _, err := s.Conn.Write(p.data); err != nil {
+		s.lock.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.lock.Unlock()
+		s.shutdown()
+		return false
+	}
+	return true
+}
+
+func (s *SimulatedConn) loopRead() {
+	for {
+		buf := make([]byte, 4096)
+		n, err := s.Conn.Read(buf)
+		s.lock.Lock()
+		due := s.in.schedule(s.conditions, n)
+		s.lock.Unlock()
+		if n > 0 {
+			select {
+			case s.reads <- simulatedPacket{data: buf[:n], due: due}:
+			case <-s.closed:
+				return
+			}
+		}
+		if err != nil {
+			select {
+			case s.reads <- simulatedPacket{due: due, err: err}:
+			case <-s.closed:
+			}
+			return
+		}
+	}
+}
+
+// schedule returns when n bytes sent now should be delivered.
+func (p *simulatedPipe) schedule(c Conditions, n int) time.Time {
+	now := time.Now()
+	start := now
+	if p.free.After(start) {
+		start = p.free
+	}
+	var transmit time.Duration
+	if c.Bandwidth > 0 {
+		transmit = time.Duration(n) * time.Second / time.Duration(c.Bandwidth)
+	}
+	p.free = start.Add(transmit)
+
+	delay := c.Latency
+	if c.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(c.Jitter)*2+1)) - c.Jitter
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	due := p.free.Add(delay)
+	if due.Before(p.lastDue) {
+		due = p.lastDue
+	}
+	p.lastDue = due
+	return due
+}
@@ -0,0 +1,74 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSimulatedConnCloseDelivers(t *testing.T) {
+	client, server := net.Pipe()
+	s := NewSimulatedConn(client, Conditions{Latency: 10 * time.Millisecond})
+	for _, b := range []string{"one ", "two ", "three"} {
+		if _, err := s.Write([]byte(b)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write([]byte("late")); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Write after Close got %v, want %v", err, net.ErrClosed)
+	}
+	b, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "one two three" {
+		t.Fatalf("got %q, want data written before Close", b)
+	}
+}
+
+func TestSimulatedConnCloseBlockedWrite(t *testing.T) {
+	// Nothing reads from the other end of the pipe, so the queue fills.
+	client, _ := net.Pipe()
+	s := NewSimulatedConn(client, Conditions{})
+	defer s.Disconnect()
+
+	blocked := make(chan error)
+	go func() {
+		for {
+			if _, err := s.Write([]byte("x")); err != nil {
+				blocked <- err
+				return
+			}
+		}
+	}()
+	// Wait for the queue to fill.
+	for len(s.writes) < cap(s.writes) {
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan error)
+	go func() {
+		closed <- s.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked behind a Write")
+	}
+	select {
+	case err := <-blocked:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("blocked Write got %v, want %v", err, net.ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Write stayed blocked after Close")
+	}
+}
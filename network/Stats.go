@@ -0,0 +1,124 @@
+package network
+
+import (
+	"maps"
+	"sync"
+)
+
+// Our Stats error kinds.
+const (
+	SendError    = "send"
+	ReceiveError = "receive"
+)
+
+// CommandStats are the message and byte counts for a single Command type.
+type CommandStats struct {
+	Messages uint64
+	Bytes    uint64
+}
+
+// Stats are the traffic statistics for a Connection or, with GlobalStats, for all Connections. Commands are keyed by their registered name as per CommandName. Stats are safe for concurrent use.
+type Stats struct {
+	lock       sync.Mutex
+	sent       map[string]CommandStats
+	received   map[string]CommandStats
+	errors     map[string]uint64
+	queueDepth func() int // queueDepth returns the count of received Commands waiting to be read, if known.
+}
+
+// StatsSnapshot is a copy of Stats at a given moment.
+type StatsSnapshot struct {
+	Sent       map[string]CommandStats
+	Received   map[string]CommandStats
+	Errors     map[string]uint64
+	QueueDepth int // Commands received by LoopCmd that are waiting to be read from the CmdChan.
+}
+
+// GlobalStats contains the combined statistics of all Connections.
+var GlobalStats = Stats{queueDepth: QueueDepth}
+
+// Snapshot returns a copy of the current statistics.
+func (s *Stats) Snapshot() (snapshot StatsSnapshot) {
+	if s.queueDepth != nil {
+		snapshot.QueueDepth = s.queueDepth()
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	snapshot.Sent = maps.Clone(s.sent)
+	snapshot.Received = maps.Clone(s.received)
+	snapshot.Errors = maps.Clone(s.errors)
+	return
+}
+
+func (s *Stats) add(m *map[string]CommandStats, name string, bytes int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if *m == nil {
+		*m = make(map[string]CommandStats)
+	}
+	c := (*m)[name]
+	c.Messages++
+	c.Bytes += uint64(bytes)
+	(*m)[name] = c
+}
+
+func (s *Stats) recordSent(name string, bytes int64) {
+	s.add(&s.sent, name, bytes)
+	if s != &GlobalStats {
+		GlobalStats.recordSent(name, bytes)
+	}
+}
+
+func (s *Stats) recordReceived(name string, bytes int64) {
+	s.add(&s.received, name, bytes)
+	if s != &GlobalStats {
+		GlobalStats.recordReceived(name, bytes)
+	}
+}
+
+func (s *Stats) recordError(kind string) {
+	s.lock.Lock()
+	if s.errors == nil {
+		s.errors = make(map[string]uint64)
+	}
+	s.errors[kind]++
+	s.lock.Unlock()
+	if s != &GlobalStats {
+		GlobalStats.recordError(kind)
+	}
+}
+
+// connections are all currently open Connections.
+var connections = struct {
+	sync.Mutex
+	set map[*Connection]struct{}
+}{set: make(map[*Connection]struct{})}
+
+func trackConnection(c *Connection) {
+	connections.Lock()
+	defer connections.Unlock()
+	connections.set[c] = struct{}{}
+}
+
+func untrackConnection(c *Connection) {
+	connections.Lock()
+	defer connections.Unlock()
+	delete(connections.set, c)
+}
+
+// OpenConnections returns the count of open Connections.
+func OpenConnections() int {
+	connections.Lock()
+	defer connections.Unlock()
+	return len(connections.set)
+}
+
+// QueueDepth returns the count of Commands received by LoopCmd that are waiting to be read from the CmdChan of all open Connections.
+func QueueDepth() (depth int) {
+	connections.Lock()
+	defer connections.Unlock()
+	for c := range connections.set {
+		depth += int(c.pending.Load())
+	}
+	return
+}
@@ -0,0 +1,309 @@
+// Package golden keeps golden encodings of every registered Command and object payload and checks the current protocol against them.
+//
+// A golden directory holds one <name>.gob file per registered gob name, containing a deterministically populated sample of that Command, and a schema.json describing the type IDs of the Commands and the fields and named field values of every struct reachable from them. A change is incompatible if a registered name disappears, a Command's type ID changes, a golden encoding can no longer be decoded into the same registered type, a field is removed or changes type, or a named value is removed or renumbered. Added commands, fields, and named values are compatible but require the golden files to be regenerated with Update.
+package golden
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// SchemaFile is the name of the schema file within a golden directory.
+const SchemaFile = "schema.json"
+
+// Field is a single struct field.
+type Field struct {
+	Name      string
+	Type      string
+	Constants map[string]int64 `json:",omitempty"` // Named values of the field.
+}
+
+// Schema describes the registered names and the fields of every reachable struct type.
+type Schema struct {
+	Version  int                // Version is the network.Version the schema was generated with.
+	Commands map[string]string  // Registered name to Go type.
+	TypeIDs  map[string]uint32  // Registered name of Commands to their GetType value.
+	Types    map[string][]Field // Go type to fields.
+}
+
+// Problem is a single difference between the golden files and the current protocol.
+type Problem struct {
+	Name         string // Registered name or Go type that the problem concerns.
+	Message      string
+	Incompatible bool // Incompatible problems break deployed clients. Others only require regeneration.
+}
+
+func (p Problem) String() string {
+	kind := "changed"
+	if p.Incompatible {
+		kind = "INCOMPATIBLE"
+	}
+	return fmt.Sprintf("%s: %s: %s", kind, p.Name, p.Message)
+}
+
+// Update writes the golden encodings and schema for the current protocol to dir. Existing encodings that still decode to the current sample are kept.
+func Update(dir string) error {
+	network.RegisterCommands()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, name := range network.CommandNames() {
+		// Keep encodings that are still current, as their bytes change with unrelated types.
+		if _, ok := checkDecode(dir, name); ok {
+			continue
+		}
+		b, err := encode(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".gob"), b, 0644); err != nil {
+			return err
+		}
+	}
+	b, err := json.MarshalIndent(CurrentSchema(), "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, SchemaFile), append(b, '\n'), 0644)
+}
+
+// ReadSchema reads the golden schema from dir.
+func ReadSchema(dir string) (s Schema, err error) {
+	b, err := os.ReadFile(filepath.Join(dir, SchemaFile))
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &s)
+	return
+}
+
+// Check compares the current protocol against the golden files in dir.
+func Check(dir string) (problems []Problem, err error) {
+	network.RegisterCommands()
+	golden, err := ReadSchema(dir)
+	if err != nil {
+		return nil, err
+	}
+	current := CurrentSchema()
+
+	for _, name := range sortedKeys(golden.Commands) {
+		if _, ok := current.Commands[name]; !ok {
+			problems = append(problems, Problem{name, "registered name was removed", true})
+			continue
+		}
+		if id, ok := current.TypeIDs[name]; ok {
+			if goldenID, ok := golden.TypeIDs[name]; !ok {
+				problems = append(problems, Problem{name, "type ID is not recorded", false})
+			} else if id != goldenID {
+				problems = append(problems, Problem{name, fmt.Sprintf("type ID changed from %d to %d", goldenID, id), true})
+			}
+		}
+		if p, ok := checkDecode(dir, name); !ok {
+			problems = append(problems, p)
+		}
+	}
+	for _, name := range sortedKeys(current.Commands) {
+		if _, ok := golden.Commands[name]; !ok {
+			problems = append(problems, Problem{name, "registered name was added", false})
+		}
+	}
+
+	for _, t := range sortedKeys(golden.Types) {
+		fields, ok := current.Types[t]
+		if !ok {
+			// Renamed or no longer reachable types are caught by their fields' types changing.
+			continue
+		}
+		for _, f := range golden.Types[t] {
+			i := slices.IndexFunc(fields, func(c Field) bool { return c.Name == f.Name })
+			if i < 0 {
+				problems = append(problems, Problem{t, fmt.Sprintf("field %s was removed", f.Name), true})
+			} else if fields[i].Type != f.Type {
+				problems = append(problems, Problem{t, fmt.Sprintf("field %s changed type from %s to %s", f.Name, f.Type, fields[i].Type), true})
+			} else {
+				problems = append(problems, checkConstants(t, f, fields[i])...)
+			}
+		}
+		for _, f := range fields {
+			if !slices.ContainsFunc(golden.Types[t], func(g Field) bool { return g.Name == f.Name }) {
+				problems = append(problems, Problem{t, fmt.Sprintf("field %s was added", f.Name), false})
+			}
+		}
+	}
+	for _, t := range sortedKeys(current.Types) {
+		if _, ok := golden.Types[t]; !ok {
+			problems = append(problems, Problem{t, "type was added", false})
+		}
+	}
+	return
+}
+
+// checkConstants compares the named values of a golden field with the current one.
+func checkConstants(t string, golden, current Field) (problems []Problem) {
+	for _, name := range sortedKeys(golden.Constants) {
+		value, ok := current.Constants[name]
+		if !ok {
+			problems = append(problems, Problem{t, fmt.Sprintf("value %s of field %s was removed", name, golden.Name), true})
+		} else if value != golden.Constants[name] {
+			problems = append(problems, Problem{t, fmt.Sprintf("value %s of field %s changed from %d to %d", name, golden.Name, golden.Constants[name], value), true})
+		}
+	}
+	for _, name := range sortedKeys(current.Constants) {
+		if _, ok := golden.Constants[name]; !ok {
+			problems = append(problems, Problem{t, fmt.Sprintf("value %s of field %s was added", name, golden.Name), false})
+		}
+	}
+	return
+}
+
+// checkDecode decodes the golden encoding of the given name and ensures it decodes into the same registered type.
+func checkDecode(dir string, name string) (Problem, bool) {
+	b, err := os.ReadFile(filepath.Join(dir, name+".gob"))
+	if err != nil {
+		return Problem{name, err.Error(), false}, false
+	}
+	var v interface{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+		return Problem{name, fmt.Sprintf("golden encoding no longer decodes: %v", err), true}, false
+	}
+	if got := network.CommandName(v); got != name {
+		return Problem{name, fmt.Sprintf("golden encoding decodes as %q (%T)", got, v), true}, false
+	}
+	// Gob type IDs depend on the order types are first seen within the process, so the bytes themselves cannot be compared.
+	if !reflect.DeepEqual(v, sample(name)) {
+		return Problem{name, "golden encoding decodes differently from the current sample", false}, false
+	}
+	return Problem{}, true
+}
+
+// encode returns the gob encoding of the populated sample of the given registered name, encoded as an interface value as Connection does.
+func encode(name string) ([]byte, error) {
+	v := sample(name)
+	if v == nil {
+		return nil, errors.New("no registered value")
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sample returns a populated value for the given registered name.
+func sample(name string) interface{} {
+	zero := network.CommandValue(name)
+	if zero == nil {
+		return nil
+	}
+	v := reflect.New(reflect.TypeOf(zero)).Elem()
+	populate(v, 0)
+	return v.Interface()
+}
+
+var payloadType = reflect.TypeOf((*network.CommandObjectPayload)(nil)).Elem()
+
+// populate fills v with deterministic non-zero values. Maps and slices receive a single element so that encodings are deterministic.
+func populate(v reflect.Value, depth int) {
+	if depth > 8 {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1.5)
+	case reflect.String:
+		v.SetString("s")
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), 1, 1)
+		populate(s.Index(0), depth+1)
+		v.Set(s)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			populate(v.Index(i), depth+1)
+		}
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		k := reflect.New(v.Type().Key()).Elem()
+		e := reflect.New(v.Type().Elem()).Elem()
+		populate(k, depth+1)
+		populate(e, depth+1)
+		m.SetMapIndex(k, e)
+		v.Set(m)
+	case reflect.Struct:
+		if opaque(v.Type()) {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				populate(v.Field(i), depth+1)
+			}
+		}
+	case reflect.Interface:
+		// Only object payloads are known to be registered. Other interfaces are left nil.
+		if v.Type() == payloadType {
+			p := reflect.New(reflect.TypeOf(network.CommandObjectPayloadCreate{})).Elem()
+			populate(p, depth+1)
+			v.Set(p)
+		}
+	}
+}
+
+// opaque returns whether a struct type encodes itself rather than by its fields.
+func opaque(t reflect.Type) bool {
+	return t.Implements(reflect.TypeOf((*gob.GobEncoder)(nil)).Elem()) || reflect.PointerTo(t).Implements(reflect.TypeOf((*gob.GobEncoder)(nil)).Elem())
+}
+
+// CurrentSchema returns the Schema of the current protocol.
+func CurrentSchema() Schema {
+	protocol := network.Schema()
+	s := Schema{
+		Version:  protocol.Version,
+		Commands: make(map[string]string),
+		TypeIDs:  make(map[string]uint32),
+		Types:    make(map[string][]Field),
+	}
+	for _, ts := range append(protocol.Commands, protocol.Types...) {
+		if ts.WireName != "" {
+			s.Commands[ts.WireName] = ts.Name
+			if !ts.Payload {
+				s.TypeIDs[ts.WireName] = ts.TypeID
+			}
+		}
+		fields := make([]Field, len(ts.Fields))
+		for i, f := range ts.Fields {
+			fields[i] = Field{Name: f.Name, Type: f.Type}
+			if len(f.Constants) > 0 {
+				fields[i].Constants = make(map[string]int64, len(f.Constants))
+				for _, c := range f.Constants {
+					fields[i].Constants[c.Name] = c.Value
+				}
+			}
+		}
+		s.Types[ts.Name] = fields
+	}
+	return s
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
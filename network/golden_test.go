@@ -0,0 +1,88 @@
+package network_test
+
+import (
+	"encoding/json"
+	"flag"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/chimera-rpg/go-common/network"
+	"github.com/chimera-rpg/go-common/network/golden"
+)
+
+var update = flag.Bool("update", false, "regenerate the golden files in testdata/golden")
+
+const goldenDir = "testdata/golden"
+
+// TestGolden checks the protocol against the golden encodings. Intentional changes are recorded with go test -run TestGolden -update.
+func TestGolden(t *testing.T) {
+	if *update {
+		if err := golden.Update(goldenDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	problems, err := golden.Check(goldenDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Error(p)
+	}
+}
+
+// TestGoldenRenumbered checks that renumbering a Command's type ID or a named field value is incompatible.
+func TestGoldenRenumbered(t *testing.T) {
+	dir := t.TempDir()
+	entries, err := os.ReadDir(goldenDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(goldenDir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, e.Name()), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schema, err := golden.ReadSchema(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Swap TypeAck with TypeInputAck, and Recover with ResetPassword.
+	ack, inputAck := network.CommandName(network.CommandAck{}), network.CommandName(network.CommandInputAck{})
+	schema.TypeIDs[ack], schema.TypeIDs[inputAck] = schema.TypeIDs[inputAck], schema.TypeIDs[ack]
+	login := schema.Types["network.CommandLogin"]
+	i := slices.IndexFunc(login, func(f golden.Field) bool { return f.Name == "Type" })
+	constants := login[i].Constants
+	constants["Recover"], constants["ResetPassword"] = constants["ResetPassword"], constants["Recover"]
+	b, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, golden.SchemaFile), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := golden.Check(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	incompatible := make(map[string]int)
+	for _, p := range problems {
+		if !p.Incompatible {
+			t.Errorf("unexpected problem %s", p)
+			continue
+		}
+		incompatible[p.Name]++
+	}
+	want := map[string]int{ack: 1, inputAck: 1, "network.CommandLogin": 2}
+	if !maps.Equal(incompatible, want) {
+		t.Fatalf("got incompatible problems %v, want %v", incompatible, want)
+	}
+}
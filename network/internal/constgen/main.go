@@ -0,0 +1,78 @@
+// Command constgen generates the named values of Command fields from the const blocks that declare them, so that they are only listed once.
+//
+// Usage:
+//
+//	constgen -o Constants.go Command.go...
+//
+// A const block is included if its doc comment ends with a "//network:constants <name>" directive, which declares a []Constant variable called <name>Constants holding every constant of the block in order.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+)
+
+const directive = "//network:constants "
+
+func main() {
+	output := flag.String("o", "Constants.go", "output file")
+	flag.Parse()
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by constgen from the //network:constants blocks; DO NOT EDIT.\n\npackage network\n\n// Our named values of Command fields.\nvar (\n")
+	fset := token.NewFileSet()
+	for _, path := range flag.Args() {
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, decl := range f.Decls {
+			d, ok := decl.(*ast.GenDecl)
+			if !ok || d.Tok != token.CONST || d.Doc == nil {
+				continue
+			}
+			name, ok := constantsName(d.Doc)
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(&b, "%sConstants = []Constant{\n", name)
+			for _, spec := range d.Specs {
+				for _, n := range spec.(*ast.ValueSpec).Names {
+					if n.Name != "_" {
+						fmt.Fprintf(&b, "{%q, %s},\n", n.Name, n.Name)
+					}
+				}
+			}
+			b.WriteString("}\n")
+		}
+	}
+	b.WriteString(")\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// constantsName returns the name given by the doc comment's directive, if any.
+func constantsName(doc *ast.CommentGroup) (string, bool) {
+	for _, c := range doc.List {
+		if name, ok := strings.CutPrefix(c.Text, directive); ok {
+			return strings.TrimSpace(name), true
+		}
+	}
+	return "", false
+}
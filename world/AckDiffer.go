@@ -0,0 +1,143 @@
+package world
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// AckDiffer is a Differ that computes updates against the last snapshot the client has acknowledged via CommandAck rather than the last snapshot sent. Any state that changed in sent but unacknowledged snapshots is resent in full, so the resulting updates are correct whether or not the client has applied them.
+type AckDiffer struct {
+	acked     *Snapshot
+	ackedTick uint32
+	pending   []tickedSnapshot
+}
+
+type tickedSnapshot struct {
+	tick     uint32
+	snapshot *Snapshot
+}
+
+// Update returns the commands required to bring the client to the given snapshot, stamped with the given tick. The given snapshot must not be modified afterwards.
+func (d *AckDiffer) Update(tick uint32, s *Snapshot) []network.Command {
+	cmds := Diff(d.baseline(s), s)
+	d.pending = append(d.pending, tickedSnapshot{tick: tick, snapshot: s})
+	return stampTick(cmds, tick)
+}
+
+// Snapshot returns the given snapshot as a CommandSnapshot stamped with the given tick. As a snapshot replaces the client's state entirely, it is treated as acknowledged.
+func (d *AckDiffer) Snapshot(tick uint32, s *Snapshot) network.CommandSnapshot {
+	d.acked = s
+	d.ackedTick = tick
+	d.pending = d.pending[:0]
+	c := s.Command()
+	c.Tick = tick
+	return c
+}
+
+// Ack marks the snapshot of the given tick, and all before it, as applied by the client.
+func (d *AckDiffer) Ack(tick uint32) {
+	if tick <= d.ackedTick {
+		return
+	}
+	i := 0
+	for ; i < len(d.pending); i++ {
+		if d.pending[i].tick > tick {
+			break
+		}
+		d.acked = d.pending[i].snapshot
+		d.ackedTick = d.pending[i].tick
+	}
+	d.pending = slices.Delete(d.pending, 0, i)
+}
+
+// AckedTick returns the last acknowledged tick.
+func (d *AckDiffer) AckedTick() uint32 {
+	return d.ackedTick
+}
+
+// Pending returns the count of sent snapshots that have not been acknowledged.
+func (d *AckDiffer) Pending() int {
+	return len(d.pending)
+}
+
+// Reset forgets all snapshots, causing the next Update to send the complete state.
+func (d *AckDiffer) Reset() {
+	d.acked = nil
+	d.ackedTick = 0
+	d.pending = d.pending[:0]
+}
+
+// baseline returns the acknowledged snapshot adjusted for the unacknowledged ones. Entries that differ in any pending snapshot are removed so they are sent in full, or kept if they no longer exist in cur so that they are removed.
+func (d *AckDiffer) baseline(cur *Snapshot) *Snapshot {
+	base := NewSnapshot()
+	if d.acked != nil {
+		maps.Copy(base.Tiles, d.acked.Tiles)
+		maps.Copy(base.Objects, d.acked.Objects)
+		base.ViewTarget = d.acked.ViewTarget
+		base.ViewHeight, base.ViewWidth, base.ViewDepth = d.acked.ViewHeight, d.acked.ViewWidth, d.acked.ViewDepth
+	}
+	acked := d.acked
+	if acked == nil {
+		acked = NewSnapshot()
+	}
+	for _, p := range d.pending {
+		for id, o := range p.snapshot.Objects {
+			if a, ok := acked.Objects[id]; ok && reflect.DeepEqual(a, o) {
+				continue
+			}
+			if _, ok := cur.Objects[id]; ok {
+				delete(base.Objects, id)
+			} else {
+				base.Objects[id] = o
+			}
+		}
+		for pos, t := range p.snapshot.Tiles {
+			if a, ok := acked.Tiles[pos]; ok && reflect.DeepEqual(a, t) {
+				continue
+			}
+			if _, ok := cur.Tiles[pos]; ok {
+				delete(base.Tiles, pos)
+			} else if len(t.ObjectIDs) > 0 {
+				base.Tiles[pos] = t
+			}
+		}
+		// Entries removed by a pending snapshot may be missing on the client.
+		for id := range acked.Objects {
+			if _, ok := p.snapshot.Objects[id]; !ok {
+				if _, ok := cur.Objects[id]; ok {
+					delete(base.Objects, id)
+				}
+			}
+		}
+		for pos := range acked.Tiles {
+			if _, ok := p.snapshot.Tiles[pos]; !ok {
+				if _, ok := cur.Tiles[pos]; ok {
+					delete(base.Tiles, pos)
+				}
+			}
+		}
+		if p.snapshot.ViewTarget != acked.ViewTarget || p.snapshot.ViewHeight != acked.ViewHeight || p.snapshot.ViewWidth != acked.ViewWidth || p.snapshot.ViewDepth != acked.ViewDepth {
+			// Guarantee the view target differs from cur so that it is resent.
+			base.ViewTarget = ^cur.ViewTarget
+		}
+	}
+	return base
+}
+
+// stampTick sets the tick of all ticked commands returned by Diff.
+func stampTick(cmds []network.Command, tick uint32) []network.Command {
+	for i, cmd := range cmds {
+		switch c := cmd.(type) {
+		case network.CommandObjects:
+			c.Tick = tick
+			cmds[i] = c
+		case network.CommandTiles:
+			c.Tick = tick
+			cmds[i] = c
+		}
+	}
+	return cmds
+}
@@ -0,0 +1,191 @@
+package world
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/chimera-rpg/go-common/data"
+)
+
+// ackSnapshots returns a sequence of snapshots that move, animate, delete, create, and recreate objects.
+func ackSnapshots() []*Snapshot {
+	var snapshots []*Snapshot
+	s := NewSnapshot()
+	s.Objects[1] = Object{ID: 1, TypeID: 1, Height: 1, Width: 1, Depth: 1}
+	s.Objects[2] = Object{ID: 2, TypeID: 2, AnimationID: 1, Height: 1, Width: 1, Depth: 1}
+	s.Tiles[Position{0, 0, 0}] = Tile{ObjectIDs: []uint32{1}, R: 10}
+	s.Tiles[Position{1, 0, 0}] = Tile{ObjectIDs: []uint32{2}}
+	snapshots = append(snapshots, s)
+
+	// Object 1 moves onto object 2, which changes animation.
+	s = NewSnapshot()
+	s.Objects[1] = Object{ID: 1, TypeID: 1, Height: 1, Width: 1, Depth: 1}
+	s.Objects[2] = Object{ID: 2, TypeID: 2, AnimationID: 2, Height: 1, Width: 1, Depth: 1}
+	s.Tiles[Position{0, 0, 0}] = Tile{R: 10}
+	s.Tiles[Position{1, 0, 0}] = Tile{ObjectIDs: []uint32{2, 1}}
+	snapshots = append(snapshots, s)
+
+	// Object 2 is removed and object 3 appears with Info. The view target is set.
+	s = NewSnapshot()
+	s.Objects[1] = Object{ID: 1, TypeID: 1, Height: 1, Width: 1, Depth: 1}
+	s.Objects[3] = Object{ID: 3, TypeID: 3, Height: 1, Width: 1, Depth: 1, Info: []data.ObjectInfo{{Name: "three"}}}
+	s.Tiles[Position{0, 0, 0}] = Tile{ObjectIDs: []uint32{3}, R: 10}
+	s.Tiles[Position{1, 0, 0}] = Tile{ObjectIDs: []uint32{1}}
+	s.ViewTarget, s.ViewHeight, s.ViewWidth, s.ViewDepth = 1, 4, 4, 4
+	snapshots = append(snapshots, s)
+
+	// Object 3 is recreated with a new type but the same Info, and a light changes.
+	s = NewSnapshot()
+	s.Objects[1] = Object{ID: 1, TypeID: 1, Height: 1, Width: 1, Depth: 1}
+	s.Objects[3] = Object{ID: 3, TypeID: 4, Height: 2, Width: 1, Depth: 1, Info: []data.ObjectInfo{{Name: "three"}}}
+	s.Tiles[Position{0, 0, 0}] = Tile{ObjectIDs: []uint32{3}, R: 20}
+	s.Tiles[Position{1, 0, 0}] = Tile{ObjectIDs: []uint32{1}}
+	s.ViewTarget, s.ViewHeight, s.ViewWidth, s.ViewDepth = 1, 4, 4, 4
+	snapshots = append(snapshots, s)
+
+	// Everything but object 1 disappears.
+	s = NewSnapshot()
+	s.Objects[1] = Object{ID: 1, TypeID: 1, Height: 1, Width: 1, Depth: 1}
+	s.Tiles[Position{1, 0, 0}] = Tile{ObjectIDs: []uint32{1}, G: 5}
+	snapshots = append(snapshots, s)
+	return snapshots
+}
+
+// checkReplica reports any difference between the world and the snapshot.
+func checkReplica(t *testing.T, tick uint32, w *World, s *Snapshot) {
+	t.Helper()
+	if got := len(w.Objects()); got != len(s.Objects) {
+		t.Errorf("tick %d: %d objects, want %d", tick, got, len(s.Objects))
+	}
+	for id, want := range s.Objects {
+		got, ok := w.Object(id)
+		if !ok {
+			t.Errorf("tick %d: object %d missing", tick, id)
+		} else if !reflect.DeepEqual(*got, want) {
+			t.Errorf("tick %d: object %d is %+v, want %+v", tick, id, *got, want)
+		}
+	}
+	for x := uint32(0); x < 2; x++ {
+		want := s.Tiles[Position{x, 0, 0}]
+		if got := w.ObjectsAt(x, 0, 0); !slices.Equal(got, want.ObjectIDs) {
+			t.Errorf("tick %d: tile %d has objects %v, want %v", tick, x, got, want.ObjectIDs)
+		}
+		if _, ok := s.Tiles[Position{x, 0, 0}]; !ok {
+			continue
+		}
+		if r, g, b, _ := w.Light(x, 0, 0); r != want.R || g != want.G || b != want.B {
+			t.Errorf("tick %d: tile %d has light %d,%d,%d, want %d,%d,%d", tick, x, r, g, b, want.R, want.G, want.B)
+		}
+	}
+	if id, h, wi, d := w.ViewTarget(); id != s.ViewTarget || h != s.ViewHeight || wi != s.ViewWidth || d != s.ViewDepth {
+		t.Errorf("tick %d: view target %d %dx%dx%d, want %d %dx%dx%d", tick, id, h, wi, d, s.ViewTarget, s.ViewHeight, s.ViewWidth, s.ViewDepth)
+	}
+}
+
+func TestAckDiffer(t *testing.T) {
+	// Each step is an Update for the tick after the initial snapshot of tick 1. The last step is always delivered.
+	type step struct {
+		lost bool     // The update never reaches the client.
+		acks []uint32 // Acks received by the server after the update is sent.
+	}
+	tests := []struct {
+		name        string
+		steps       []step
+		wantAcked   uint32
+		wantPending int
+	}{
+		{
+			name:        "all acked",
+			steps:       []step{{acks: []uint32{2}}, {acks: []uint32{3}}, {acks: []uint32{4}}, {}},
+			wantAcked:   4,
+			wantPending: 1,
+		},
+		{
+			name:        "no acks",
+			steps:       []step{{}, {}, {}, {}},
+			wantAcked:   1,
+			wantPending: 4,
+		},
+		{
+			name:        "lost update",
+			steps:       []step{{acks: []uint32{2}}, {lost: true}, {acks: []uint32{4}}, {}},
+			wantAcked:   4,
+			wantPending: 1,
+		},
+		{
+			name:        "lost updates without acks",
+			steps:       []step{{lost: true}, {}, {lost: true}, {}},
+			wantAcked:   1,
+			wantPending: 4,
+		},
+		{
+			name:        "duplicated acks",
+			steps:       []step{{acks: []uint32{2, 2}}, {acks: []uint32{2, 3, 3}}, {}, {}},
+			wantAcked:   3,
+			wantPending: 2,
+		},
+		{
+			name:        "out of order acks",
+			steps:       []step{{}, {}, {acks: []uint32{4, 3, 2}}, {acks: []uint32{3}}},
+			wantAcked:   4,
+			wantPending: 1,
+		},
+		{
+			name:        "acks skipping ticks",
+			steps:       []step{{}, {acks: []uint32{3}}, {}, {acks: []uint32{5}}},
+			wantAcked:   5,
+			wantPending: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshots := ackSnapshots()
+			var d AckDiffer
+			w := NewWorld()
+			w.Apply(d.Snapshot(1, snapshots[0]))
+			checkReplica(t, 1, w, snapshots[0])
+			for i, st := range tt.steps {
+				tick := uint32(i + 2)
+				s := snapshots[i+1]
+				cmds := d.Update(tick, s)
+				if !st.lost {
+					for _, cmd := range cmds {
+						w.Apply(cmd)
+					}
+					checkReplica(t, tick, w, s)
+				}
+				for _, ack := range st.acks {
+					d.Ack(ack)
+				}
+			}
+			if got := d.AckedTick(); got != tt.wantAcked {
+				t.Errorf("acked tick %d, want %d", got, tt.wantAcked)
+			}
+			if got := d.Pending(); got != tt.wantPending {
+				t.Errorf("%d pending, want %d", got, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestAckDifferSnapshotResetsPending(t *testing.T) {
+	snapshots := ackSnapshots()
+	var d AckDiffer
+	d.Update(1, snapshots[0])
+	d.Update(2, snapshots[1])
+	w := NewWorld()
+	w.Apply(d.Snapshot(3, snapshots[2]))
+	if d.Pending() != 0 || d.AckedTick() != 3 {
+		t.Fatalf("after snapshot: %d pending, acked tick %d, want 0 and 3", d.Pending(), d.AckedTick())
+	}
+	// A late ack of an update sent before the snapshot is ignored.
+	d.Ack(2)
+	if d.AckedTick() != 3 {
+		t.Fatalf("late ack moved acked tick to %d", d.AckedTick())
+	}
+	for _, cmd := range d.Update(4, snapshots[3]) {
+		w.Apply(cmd)
+	}
+	checkReplica(t, 4, w, snapshots[3])
+}
@@ -0,0 +1,77 @@
+package world
+
+import (
+	"time"
+
+	"github.com/chimera-rpg/go-common/data"
+)
+
+// Event is our interface for all change events emitted by a World.
+type Event interface{}
+
+// EventMap is emitted when a new map is entered. All previous tiles and objects are cleared before it is emitted.
+type EventMap struct {
+	Map Map
+}
+
+// EventTile is emitted when the objects at a tile change.
+type EventTile struct {
+	Position  Position
+	ObjectIDs []uint32
+}
+
+// EventTileLight is emitted when the light of a tile changes.
+type EventTileLight struct {
+	Position Position
+	R, G, B  uint8
+}
+
+// EventTileSky is emitted when the sky value of a tile changes.
+type EventTileSky struct {
+	Position Position
+	Sky      float64
+}
+
+// EventObjectCreate is emitted when an object is created.
+type EventObjectCreate struct {
+	Object *Object
+}
+
+// EventObjectDelete is emitted when an object is deleted. The Object is no longer contained in the World.
+type EventObjectDelete struct {
+	Object *Object
+}
+
+// EventObjectMove is emitted when an object is placed at a new tile. Placed is false if the object was not previously in any tile.
+type EventObjectMove struct {
+	ObjectID uint32
+	From, To Position
+	Placed   bool
+}
+
+// EventObjectAnimate is emitted when an object's animation or face changes.
+type EventObjectAnimate struct {
+	Object *Object
+}
+
+// EventObjectInfo is emitted when an object's information is updated.
+type EventObjectInfo struct {
+	Object *Object
+}
+
+// EventViewTarget is emitted when the view target changes.
+type EventViewTarget struct {
+	ObjectID             uint32
+	Height, Width, Depth uint8
+}
+
+// EventStatus is emitted when the active status effects change.
+type EventStatus struct {
+	Statuses data.StatusType
+}
+
+// EventStamina is emitted when the view target's stamina changes.
+type EventStamina struct {
+	Stamina    time.Duration
+	MaxStamina time.Duration
+}
@@ -0,0 +1,39 @@
+package world
+
+import (
+	"github.com/chimera-rpg/go-common/data"
+)
+
+// Position is the X, Y, Z location of a tile.
+type Position struct {
+	X, Y, Z uint32
+}
+
+// Object is the representation of an object as created by CommandObjectPayloadCreate.
+type Object struct {
+	ID                   uint32
+	TypeID               uint8
+	AnimationID          uint32
+	FaceID               uint32
+	Height, Width, Depth uint8
+	Reach                uint8
+	Opaque               bool
+	Info                 []data.ObjectInfo
+}
+
+// Tile is the representation of a single map tile.
+type Tile struct {
+	ObjectIDs []uint32
+	R, G, B   uint8
+	Sky       float64
+}
+
+// Map is the client-side representation of the current map as sent by CommandMap.
+type Map struct {
+	MapID                                 uint32
+	Name                                  string
+	Height, Width, Depth                  int
+	Outdoor                               bool
+	OutdoorRed, OutdoorGreen, OutdoorBlue uint8
+	AmbientRed, AmbientGreen, AmbientBlue uint8
+}
@@ -0,0 +1,130 @@
+package world
+
+import (
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// MoveFunc returns the position that results from applying the given CommandCmd.Cmd at the given position. It returns false if the command does not move.
+type MoveFunc func(p Position, cmd int) (Position, bool)
+
+// DirectionalMove is the default MoveFunc. It moves a single tile for the directional commands North through Down, with Y being height, X being width, and Z being depth. Moves below zero on any axis are rejected, as they would leave the map.
+func DirectionalMove(p Position, cmd int) (Position, bool) {
+	var dx, dy, dz int
+	switch cmd {
+	case network.North:
+		dz = -1
+	case network.South:
+		dz = 1
+	case network.East:
+		dx = 1
+	case network.West:
+		dx = -1
+	case network.Northeast:
+		dz, dx = -1, 1
+	case network.Northwest:
+		dz, dx = -1, -1
+	case network.Southeast:
+		dz, dx = 1, 1
+	case network.Southwest:
+		dz, dx = 1, -1
+	case network.Up:
+		dy = 1
+	case network.Down:
+		dy = -1
+	default:
+		return p, false
+	}
+	if (dx < 0 && p.X == 0) || (dy < 0 && p.Y == 0) || (dz < 0 && p.Z == 0) {
+		return p, false
+	}
+	p.X = uint32(int64(p.X) + int64(dx))
+	p.Y = uint32(int64(p.Y) + int64(dy))
+	p.Z = uint32(int64(p.Z) + int64(dz))
+	return p, true
+}
+
+// Predictor provides client-side movement prediction. Commands created through it are given input sequence numbers and applied immediately to the predicted position. When the server's CommandInputAck arrives, the authoritative position is adopted and any inputs that the server has not yet processed are replayed on top of it.
+type Predictor struct {
+	Move      MoveFunc // Move is used to predict movement. DirectionalMove is used if nil.
+	lastInput uint32
+	position  Position // Last authoritative position.
+	predicted Position
+	pending   []pendingInput
+}
+
+type pendingInput struct {
+	input uint32
+	cmd   int
+}
+
+// Reset sets the authoritative position and discards all pending inputs. This should be used when the server places the character, such as after a CommandSnapshot.
+func (p *Predictor) Reset(pos Position) {
+	p.position = pos
+	p.predicted = pos
+	p.pending = p.pending[:0]
+}
+
+// Position returns the predicted position.
+func (p *Predictor) Position() Position {
+	return p.predicted
+}
+
+// Pending returns the count of inputs that have not been acknowledged by the server.
+func (p *Predictor) Pending() int {
+	return len(p.pending)
+}
+
+// Cmd returns a sequenced CommandCmd and applies its predicted movement.
+func (p *Predictor) Cmd(cmd int, data interface{}) network.CommandCmd {
+	p.lastInput++
+	if pos, ok := p.move(p.predicted, cmd); ok {
+		p.predicted = pos
+	}
+	p.pending = append(p.pending, pendingInput{
+		input: p.lastInput,
+		cmd:   cmd,
+	})
+	return network.CommandCmd{
+		Cmd:   cmd,
+		Data:  data,
+		Input: p.lastInput,
+	}
+}
+
+// RepeatCmd returns a sequenced CommandRepeatCmd. Repeated commands are not predicted, as their timing is decided by the server.
+func (p *Predictor) RepeatCmd(cmd int, cancel bool, data interface{}) network.CommandRepeatCmd {
+	p.lastInput++
+	return network.CommandRepeatCmd{
+		Cmd:    cmd,
+		Cancel: cancel,
+		Data:   data,
+		Input:  p.lastInput,
+	}
+}
+
+// Reconcile applies the server's acknowledgement, replaying any unacknowledged inputs from the authoritative position. It returns true if the predicted position changed as a result.
+func (p *Predictor) Reconcile(ack network.CommandInputAck) (corrected bool) {
+	p.position = Position{ack.X, ack.Y, ack.Z}
+	i := 0
+	for i < len(p.pending) && p.pending[i].input <= ack.Input {
+		i++
+	}
+	p.pending = append(p.pending[:0], p.pending[i:]...)
+
+	predicted := p.position
+	for _, in := range p.pending {
+		if pos, ok := p.move(predicted, in.cmd); ok {
+			predicted = pos
+		}
+	}
+	corrected = predicted != p.predicted
+	p.predicted = predicted
+	return
+}
+
+func (p *Predictor) move(pos Position, cmd int) (Position, bool) {
+	if p.Move != nil {
+		return p.Move(pos, cmd)
+	}
+	return DirectionalMove(pos, cmd)
+}
@@ -0,0 +1,233 @@
+package world
+
+import (
+	"cmp"
+	"reflect"
+	"slices"
+
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// Snapshot is a description of the world as visible to a single player at a given moment. Servers build a Snapshot each tick and use Diff or a Differ to acquire the commands needed to bring the client from one snapshot to the next.
+type Snapshot struct {
+	Tiles                            map[Position]Tile
+	Objects                          map[uint32]Object
+	ViewTarget                       uint32
+	ViewHeight, ViewWidth, ViewDepth uint8
+}
+
+// NewSnapshot returns a new, empty Snapshot.
+func NewSnapshot() *Snapshot {
+	return &Snapshot{
+		Tiles:   make(map[Position]Tile),
+		Objects: make(map[uint32]Object),
+	}
+}
+
+// Differ keeps track of the last snapshot sent to a client.
+type Differ struct {
+	previous *Snapshot
+}
+
+// Update returns the commands required to move the client from the previously updated snapshot to the given one. The given snapshot must not be modified afterwards.
+func (d *Differ) Update(s *Snapshot) []network.Command {
+	cmds := Diff(d.previous, s)
+	d.previous = s
+	return cmds
+}
+
+// Snapshot returns the given snapshot as a CommandSnapshot and uses it as the baseline for the next Update. The caller is expected to fill in the map, tick, statuses, and stamina.
+func (d *Differ) Snapshot(s *Snapshot) network.CommandSnapshot {
+	d.previous = s
+	return s.Command()
+}
+
+// Reset forgets the previous snapshot, causing the next Update to send the complete state. This should be used after a map Travel.
+func (d *Differ) Reset() {
+	d.previous = nil
+}
+
+// Diff returns the minimal commands needed to move a client from the prev snapshot to the cur snapshot. If prev is nil, the complete state of cur is returned. Commands are ordered such that objects are created before tiles reference them and deleted after tiles no longer do.
+func Diff(prev, cur *Snapshot) (cmds []network.Command) {
+	if prev == nil {
+		prev = NewSnapshot()
+	}
+	if cur == nil {
+		cur = NewSnapshot()
+	}
+
+	var updates network.CommandObjects
+	for _, id := range sortedObjectIDs(cur.Objects) {
+		o := cur.Objects[id]
+		p, existed := prev.Objects[id]
+		// Changes that a CommandObjectPayloadAnimate cannot express recreate the object, which also resets its Info.
+		created := !existed || p.TypeID != o.TypeID || p.Height != o.Height || p.Width != o.Width || p.Depth != o.Depth || p.Reach != o.Reach || p.Opaque != o.Opaque
+		if created {
+			updates.ObjectUpdates = append(updates.ObjectUpdates, network.CommandObject{
+				ObjectID: id,
+				Payload: network.CommandObjectPayloadCreate{
+					TypeID:      o.TypeID,
+					AnimationID: o.AnimationID,
+					FaceID:      o.FaceID,
+					Height:      o.Height,
+					Width:       o.Width,
+					Depth:       o.Depth,
+					Reach:       o.Reach,
+					Opaque:      o.Opaque,
+				},
+			})
+		} else if p.AnimationID != o.AnimationID || p.FaceID != o.FaceID {
+			updates.ObjectUpdates = append(updates.ObjectUpdates, network.CommandObject{
+				ObjectID: id,
+				Payload: network.CommandObjectPayloadAnimate{
+					AnimationID: o.AnimationID,
+					FaceID:      o.FaceID,
+				},
+			})
+		}
+		if (created && len(o.Info) > 0) || (!created && !reflect.DeepEqual(p.Info, o.Info)) {
+			updates.ObjectUpdates = append(updates.ObjectUpdates, network.CommandObject{
+				ObjectID: id,
+				Payload: network.CommandObjectPayloadInfo{
+					Info: o.Info,
+				},
+			})
+		}
+	}
+	if len(updates.ObjectUpdates) > 0 {
+		cmds = append(cmds, updates)
+	}
+
+	var tiles network.CommandTiles
+	for _, pos := range sortedPositions(cur.Tiles) {
+		t := cur.Tiles[pos]
+		p, existed := prev.Tiles[pos]
+		if !existed || !slices.Equal(p.ObjectIDs, t.ObjectIDs) {
+			tiles.TileUpdates = append(tiles.TileUpdates, network.CommandTile{
+				X:         pos.X,
+				Y:         pos.Y,
+				Z:         pos.Z,
+				ObjectIDs: t.ObjectIDs,
+			})
+		}
+		if !existed || p.R != t.R || p.G != t.G || p.B != t.B {
+			tiles.LightUpdates = append(tiles.LightUpdates, network.CommandTileLight{
+				X: pos.X,
+				Y: pos.Y,
+				Z: pos.Z,
+				R: t.R,
+				G: t.G,
+				B: t.B,
+			})
+		}
+		if !existed || p.Sky != t.Sky {
+			tiles.SkyUpdates = append(tiles.SkyUpdates, network.CommandTileSky{
+				X:   pos.X,
+				Y:   pos.Y,
+				Z:   pos.Z,
+				Sky: t.Sky,
+			})
+		}
+	}
+	// Tiles that are no longer visible are emptied.
+	for _, pos := range sortedPositions(prev.Tiles) {
+		if _, exists := cur.Tiles[pos]; exists || len(prev.Tiles[pos].ObjectIDs) == 0 {
+			continue
+		}
+		tiles.TileUpdates = append(tiles.TileUpdates, network.CommandTile{
+			X: pos.X,
+			Y: pos.Y,
+			Z: pos.Z,
+		})
+	}
+	if len(tiles.TileUpdates) > 0 || len(tiles.LightUpdates) > 0 || len(tiles.SkyUpdates) > 0 {
+		cmds = append(cmds, tiles)
+	}
+
+	var trailing network.CommandObjects
+	for _, id := range sortedObjectIDs(prev.Objects) {
+		if _, exists := cur.Objects[id]; !exists {
+			trailing.ObjectUpdates = append(trailing.ObjectUpdates, network.CommandObject{
+				ObjectID: id,
+				Payload:  network.CommandObjectPayloadDelete{},
+			})
+		}
+	}
+	if prev.ViewTarget != cur.ViewTarget || prev.ViewHeight != cur.ViewHeight || prev.ViewWidth != cur.ViewWidth || prev.ViewDepth != cur.ViewDepth {
+		trailing.ObjectUpdates = append(trailing.ObjectUpdates, network.CommandObject{
+			ObjectID: cur.ViewTarget,
+			Payload: network.CommandObjectPayloadViewTarget{
+				Height: cur.ViewHeight,
+				Width:  cur.ViewWidth,
+				Depth:  cur.ViewDepth,
+			},
+		})
+	}
+	if len(trailing.ObjectUpdates) > 0 {
+		cmds = append(cmds, trailing)
+	}
+	return
+}
+
+// Command returns the tiles, objects, and view target of the snapshot as a CommandSnapshot.
+func (s *Snapshot) Command() (c network.CommandSnapshot) {
+	for _, id := range sortedObjectIDs(s.Objects) {
+		o := s.Objects[id]
+		c.Objects = append(c.Objects, network.CommandObject{
+			ObjectID: id,
+			Payload: network.CommandObjectPayloadCreate{
+				TypeID:      o.TypeID,
+				AnimationID: o.AnimationID,
+				FaceID:      o.FaceID,
+				Height:      o.Height,
+				Width:       o.Width,
+				Depth:       o.Depth,
+				Reach:       o.Reach,
+				Opaque:      o.Opaque,
+			},
+		})
+		if len(o.Info) > 0 {
+			c.Objects = append(c.Objects, network.CommandObject{
+				ObjectID: id,
+				Payload: network.CommandObjectPayloadInfo{
+					Info: o.Info,
+				},
+			})
+		}
+	}
+	for _, pos := range sortedPositions(s.Tiles) {
+		t := s.Tiles[pos]
+		c.Tiles.TileUpdates = append(c.Tiles.TileUpdates, network.CommandTile{X: pos.X, Y: pos.Y, Z: pos.Z, ObjectIDs: t.ObjectIDs})
+		c.Tiles.LightUpdates = append(c.Tiles.LightUpdates, network.CommandTileLight{X: pos.X, Y: pos.Y, Z: pos.Z, R: t.R, G: t.G, B: t.B})
+		c.Tiles.SkyUpdates = append(c.Tiles.SkyUpdates, network.CommandTileSky{X: pos.X, Y: pos.Y, Z: pos.Z, Sky: t.Sky})
+	}
+	c.ViewTarget = s.ViewTarget
+	c.ViewHeight, c.ViewWidth, c.ViewDepth = s.ViewHeight, s.ViewWidth, s.ViewDepth
+	return
+}
+
+func sortedObjectIDs(objects map[uint32]Object) []uint32 {
+	ids := make([]uint32, 0, len(objects))
+	for id := range objects {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func sortedPositions(tiles map[Position]Tile) []Position {
+	positions := make([]Position, 0, len(tiles))
+	for p := range tiles {
+		positions = append(positions, p)
+	}
+	slices.SortFunc(positions, func(a, b Position) int {
+		if c := cmp.Compare(a.Z, b.Z); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Y, b.Y); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.X, b.X)
+	})
+	return positions
+}
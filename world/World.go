@@ -0,0 +1,363 @@
+package world
+
+import (
+	"slices"
+	"time"
+
+	"github.com/chimera-rpg/go-common/data"
+	"github.com/chimera-rpg/go-common/network"
+)
+
+// World is a client-side replica of the visible world state. Commands received from the server are passed to Apply in the order they arrive, and any resulting changes are passed to the Handler as Events. A World is not safe for concurrent use.
+type World struct {
+	Handler    func(Event) // Handler, if set, is called for each change.
+	currentMap Map
+	tiles      map[Position]*Tile
+	objects    map[uint32]*Object
+	placements map[uint32]Position
+	viewTarget uint32
+	viewHeight uint8
+	viewWidth  uint8
+	viewDepth  uint8
+	statuses   data.StatusType
+	stamina    time.Duration
+	maxStamina time.Duration
+	baseTick   uint32 // Tick of the last snapshot.
+	tick       uint32 // Highest tick applied.
+	stale      int    // Count of updates ignored for predating the last snapshot.
+	late       int    // Count of updates that arrived after an update of a later tick.
+}
+
+// NewWorld returns a new, empty World.
+func NewWorld() *World {
+	return &World{
+		tiles:      make(map[Position]*Tile),
+		objects:    make(map[uint32]*Object),
+		placements: make(map[uint32]Position),
+	}
+}
+
+// Apply applies the given Command to the world. It returns false if the command does not affect world state or if it is stale, having been generated before the last applied CommandSnapshot.
+func (w *World) Apply(cmd network.Command) bool {
+	if t, ok := cmd.(network.TickedCommand); ok && t.GetTick() != 0 {
+		if _, ok := cmd.(network.CommandSnapshot); !ok && t.GetTick() <= w.baseTick {
+			w.stale++
+			return false
+		}
+		if t.GetTick() < w.tick {
+			w.late++
+		} else {
+			w.tick = t.GetTick()
+		}
+	}
+	switch c := cmd.(type) {
+	case network.CommandMap:
+		w.applyMap(c)
+	case network.CommandTiles:
+		for _, t := range c.TileUpdates {
+			w.applyTile(t)
+		}
+		for _, t := range c.LightUpdates {
+			w.applyTileLight(t)
+		}
+		for _, t := range c.SkyUpdates {
+			w.applyTileSky(t)
+		}
+	case network.CommandTile:
+		w.applyTile(c)
+	case network.CommandTileLight:
+		w.applyTileLight(c)
+	case network.CommandTileSky:
+		w.applyTileSky(c)
+	case network.CommandObject:
+		w.applyObject(c)
+	case network.CommandObjects:
+		for _, o := range c.ObjectUpdates {
+			w.applyObject(o)
+		}
+	case network.CommandSnapshot:
+		w.applySnapshot(c)
+	case network.CommandStatus:
+		if c.Active {
+			w.statuses |= c.Type
+		} else {
+			w.statuses &^= c.Type
+		}
+		w.emit(EventStatus{Statuses: w.statuses})
+	case network.CommandStamina:
+		w.stamina, w.maxStamina = c.Stamina, c.MaxStamina
+		w.emit(EventStamina{Stamina: c.Stamina, MaxStamina: c.MaxStamina})
+	default:
+		return false
+	}
+	return true
+}
+
+// Map returns the current map.
+func (w *World) Map() Map {
+	return w.currentMap
+}
+
+// Object returns the object with the given ID.
+func (w *World) Object(id uint32) (*Object, bool) {
+	o, ok := w.objects[id]
+	return o, ok
+}
+
+// Objects returns all known objects.
+func (w *World) Objects() []*Object {
+	objects := make([]*Object, 0, len(w.objects))
+	for _, o := range w.objects {
+		objects = append(objects, o)
+	}
+	return objects
+}
+
+// ObjectPosition returns the tile that the given object ID is currently in.
+func (w *World) ObjectPosition(id uint32) (Position, bool) {
+	p, ok := w.placements[id]
+	return p, ok
+}
+
+// ObjectsAt returns a copy of the object IDs at the given tile in their stacking order.
+func (w *World) ObjectsAt(x, y, z uint32) []uint32 {
+	if t, ok := w.tiles[Position{x, y, z}]; ok {
+		return slices.Clone(t.ObjectIDs)
+	}
+	return nil
+}
+
+// Tile returns a copy of the tile at the given position.
+func (w *World) Tile(x, y, z uint32) (Tile, bool) {
+	if t, ok := w.tiles[Position{x, y, z}]; ok {
+		c := *t
+		c.ObjectIDs = slices.Clone(t.ObjectIDs)
+		return c, true
+	}
+	return Tile{}, false
+}
+
+// Light returns the light color at the given tile.
+func (w *World) Light(x, y, z uint32) (r, g, b uint8, ok bool) {
+	if t, exists := w.tiles[Position{x, y, z}]; exists {
+		return t.R, t.G, t.B, true
+	}
+	return
+}
+
+// Sky returns the sky value at the given tile.
+func (w *World) Sky(x, y, z uint32) (sky float64, ok bool) {
+	if t, exists := w.tiles[Position{x, y, z}]; exists {
+		return t.Sky, true
+	}
+	return
+}
+
+// ViewTarget returns the object ID that is the client's view target along with its view range.
+func (w *World) ViewTarget() (id uint32, height, width, depth uint8) {
+	return w.viewTarget, w.viewHeight, w.viewWidth, w.viewDepth
+}
+
+// Statuses returns the currently active status effects.
+func (w *World) Statuses() data.StatusType {
+	return w.statuses
+}
+
+// Stamina returns the current and maximum stamina of the view target.
+func (w *World) Stamina() (stamina, maxStamina time.Duration) {
+	return w.stamina, w.maxStamina
+}
+
+// Tick returns the highest server tick that has been applied.
+func (w *World) Tick() uint32 {
+	return w.tick
+}
+
+// Ack returns a CommandAck for the highest applied tick.
+func (w *World) Ack() network.CommandAck {
+	return network.CommandAck{Tick: w.tick}
+}
+
+// TickStats returns the count of stale updates that were ignored and of updates that arrived out of order.
+func (w *World) TickStats() (stale, late int) {
+	return w.stale, w.late
+}
+
+func (w *World) emit(e Event) {
+	if w.Handler != nil {
+		w.Handler(e)
+	}
+}
+
+func (w *World) tile(p Position) *Tile {
+	t, ok := w.tiles[p]
+	if !ok {
+		t = &Tile{}
+		w.tiles[p] = t
+	}
+	return t
+}
+
+func (w *World) applyMap(c network.CommandMap) {
+	w.currentMap = Map{
+		MapID:        c.MapID,
+		Name:         c.Name,
+		Height:       c.Height,
+		Width:        c.Width,
+		Depth:        c.Depth,
+		Outdoor:      c.Outdoor,
+		OutdoorRed:   c.OutdoorRed,
+		OutdoorGreen: c.OutdoorGreen,
+		OutdoorBlue:  c.OutdoorBlue,
+		AmbientRed:   c.AmbientRed,
+		AmbientGreen: c.AmbientGreen,
+		AmbientBlue:  c.AmbientBlue,
+	}
+	w.tiles = make(map[Position]*Tile)
+	w.objects = make(map[uint32]*Object)
+	w.placements = make(map[uint32]Position)
+	w.viewTarget = 0
+	w.viewHeight, w.viewWidth, w.viewDepth = 0, 0, 0
+	w.emit(EventMap{Map: w.currentMap})
+}
+
+func (w *World) applySnapshot(c network.CommandSnapshot) {
+	w.applyMap(c.Map)
+	w.baseTick = c.Tick
+	w.tick = c.Tick
+	for _, o := range c.Objects {
+		w.applyObject(o)
+	}
+	for _, t := range c.Tiles.TileUpdates {
+		w.applyTile(t)
+	}
+	for _, t := range c.Tiles.LightUpdates {
+		w.applyTileLight(t)
+	}
+	for _, t := range c.Tiles.SkyUpdates {
+		w.applyTileSky(t)
+	}
+	w.applyObject(network.CommandObject{
+		ObjectID: c.ViewTarget,
+		Payload: network.CommandObjectPayloadViewTarget{
+			Height: c.ViewHeight,
+			Width:  c.ViewWidth,
+			Depth:  c.ViewDepth,
+		},
+	})
+	w.statuses = c.Statuses
+	w.emit(EventStatus{Statuses: w.statuses})
+	w.stamina, w.maxStamina = c.Stamina, c.MaxStamina
+	w.emit(EventStamina{Stamina: c.Stamina, MaxStamina: c.MaxStamina})
+}
+
+func (w *World) applyTile(c network.CommandTile) {
+	p := Position{c.X, c.Y, c.Z}
+	t := w.tile(p)
+	previous := t.ObjectIDs
+	t.ObjectIDs = append([]uint32(nil), c.ObjectIDs...)
+
+	current := make(map[uint32]struct{}, len(t.ObjectIDs))
+	for _, id := range t.ObjectIDs {
+		current[id] = struct{}{}
+		from, placed := w.placements[id]
+		if placed && from == p {
+			continue
+		}
+		// Objects only occupy a single tile, so remove it from the previous one.
+		if placed {
+			w.removeFromTile(from, id)
+		}
+		w.placements[id] = p
+		w.emit(EventObjectMove{ObjectID: id, From: from, To: p, Placed: placed})
+	}
+	// Unplace any objects that are no longer in this tile.
+	for _, id := range previous {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		if from, placed := w.placements[id]; placed && from == p {
+			delete(w.placements, id)
+		}
+	}
+	w.emit(EventTile{Position: p, ObjectIDs: slices.Clone(t.ObjectIDs)})
+}
+
+func (w *World) applyTileLight(c network.CommandTileLight) {
+	p := Position{c.X, c.Y, c.Z}
+	t := w.tile(p)
+	t.R, t.G, t.B = c.R, c.G, c.B
+	w.emit(EventTileLight{Position: p, R: c.R, G: c.G, B: c.B})
+}
+
+func (w *World) applyTileSky(c network.CommandTileSky) {
+	p := Position{c.X, c.Y, c.Z}
+	t := w.tile(p)
+	t.Sky = c.Sky
+	w.emit(EventTileSky{Position: p, Sky: c.Sky})
+}
+
+func (w *World) removeFromTile(p Position, id uint32) {
+	t, ok := w.tiles[p]
+	if !ok {
+		return
+	}
+	for i, oid := range t.ObjectIDs {
+		if oid == id {
+			ids := make([]uint32, 0, len(t.ObjectIDs)-1)
+			ids = append(ids, t.ObjectIDs[:i]...)
+			t.ObjectIDs = append(ids, t.ObjectIDs[i+1:]...)
+			w.emit(EventTile{Position: p, ObjectIDs: slices.Clone(t.ObjectIDs)})
+			return
+		}
+	}
+}
+
+func (w *World) applyObject(c network.CommandObject) {
+	switch p := c.Payload.(type) {
+	case network.CommandObjectPayloadCreate:
+		o := &Object{
+			ID:          c.ObjectID,
+			TypeID:      p.TypeID,
+			AnimationID: p.AnimationID,
+			FaceID:      p.FaceID,
+			Height:      p.Height,
+			Width:       p.Width,
+			Depth:       p.Depth,
+			Reach:       p.Reach,
+			Opaque:      p.Opaque,
+		}
+		w.objects[c.ObjectID] = o
+		w.emit(EventObjectCreate{Object: o})
+	case network.CommandObjectPayloadDelete:
+		o, ok := w.objects[c.ObjectID]
+		if !ok {
+			return
+		}
+		if p, placed := w.placements[c.ObjectID]; placed {
+			w.removeFromTile(p, c.ObjectID)
+			delete(w.placements, c.ObjectID)
+		}
+		delete(w.objects, c.ObjectID)
+		w.emit(EventObjectDelete{Object: o})
+	case network.CommandObjectPayloadAnimate:
+		o, ok := w.objects[c.ObjectID]
+		if !ok {
+			return
+		}
+		o.AnimationID = p.AnimationID
+		o.FaceID = p.FaceID
+		w.emit(EventObjectAnimate{Object: o})
+	case network.CommandObjectPayloadInfo:
+		o, ok := w.objects[c.ObjectID]
+		if !ok {
+			return
+		}
+		o.Info = p.Info
+		w.emit(EventObjectInfo{Object: o})
+	case network.CommandObjectPayloadViewTarget:
+		w.viewTarget = c.ObjectID
+		w.viewHeight, w.viewWidth, w.viewDepth = p.Height, p.Width, p.Depth
+		w.emit(EventViewTarget{ObjectID: c.ObjectID, Height: p.Height, Width: p.Width, Depth: p.Depth})
+	}
+}